@@ -21,8 +21,6 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
-	"google.golang.org/api/option"
-	"google.golang.org/grpc"
 )
 
 const (
@@ -135,8 +133,7 @@ func run(ctx context.Context) error {
 			MinOpened:           1,
 			TrackSessionHandles: true,
 		},
-	}, option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(interceptor.StreamInterceptor(interceptor.WithDefaultDecorators()))),
-	)
+	}, interceptor.ClientOptions(interceptor.WithDefaultDecorators())...)
 	if err != nil {
 		return err
 	}
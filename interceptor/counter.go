@@ -0,0 +1,58 @@
+package interceptor
+
+import "sync"
+
+// boundedCounter is a map of counters which holds at most max keys.
+// When it is full, the oldest inserted key is evicted. It is safe for concurrent use.
+type boundedCounter struct {
+	mu     sync.Mutex
+	max    int
+	counts map[string]int
+	order  []string
+}
+
+func newBoundedCounter(max int) *boundedCounter {
+	return &boundedCounter{
+		max:    max,
+		counts: make(map[string]int),
+	}
+}
+
+// add adds n to the counter of key and returns the new value.
+func (c *boundedCounter) add(key string, n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counts[key]; !ok {
+		if len(c.order) >= c.max {
+			delete(c.counts, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.counts[key] += n
+	return c.counts[key]
+}
+
+func (c *boundedCounter) get(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key]
+}
+
+// remove returns the counter of key and evicts it.
+func (c *boundedCounter) remove(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.counts[key]
+	if !ok {
+		return 0
+	}
+	delete(c.counts, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithDBSemanticConventions sets attributes of the OpenTelemetry database semantic conventions:
+// db.system ("spanner"), db.name (the database ID in the session name), db.statement (the SQL of ExecuteSql requests),
+// db.operation (the first keyword of the SQL like SELECT, or the RPC method like Read and Commit),
+// and server.address (the host of the gRPC target).
+// db.statement is recorded regardless of WithQueryFilter, so don't use this option if SQL may contain sensitive literals.
+func WithDBSemanticConventions() Option {
+	return func(o *interceptorOption) {
+		o.dbSemanticConventions = true
+		o.requestSpanDecorators = append(o.requestSpanDecorators, dbSemanticConventionsSpanDecorator)
+	}
+}
+
+func dbSemanticConventionsSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	attrs := []attribute.KeyValue{semconv.DBSystemKey.String("spanner")}
+	if r, ok := req.(interface{ GetSession() string }); ok {
+		if database := databaseID(r.GetSession()); database != "" {
+			attrs = append(attrs, semconv.DBNameKey.String(database))
+		}
+	}
+	method := MethodFromContext(ctx)
+	operation := method[strings.LastIndex(method, "/")+1:]
+	if r, ok := req.(*spanner.ExecuteSqlRequest); ok {
+		attrs = append(attrs, semconv.DBStatementKey.String(r.GetSql()))
+		if keyword := sqlKeyword(r.GetSql()); keyword != "" {
+			operation = keyword
+		}
+	}
+	if operation != "" {
+		attrs = append(attrs, semconv.DBOperationKey.String(operation))
+	}
+	span.SetAttributes(attrs...)
+}
+
+// databaseID returns the database ID of a session name like projects/p/instances/i/databases/d/sessions/s.
+func databaseID(session string) string {
+	return parseResourceName(session).database
+}
+
+// sqlKeyword returns the first keyword of sql in upper case, skipping leading comments and statement hints.
+func sqlKeyword(sql string) string {
+	s := strings.TrimSpace(sql)
+	for {
+		switch {
+		case strings.HasPrefix(s, "--"), strings.HasPrefix(s, "#"):
+			_, s = split2(s, "\n")
+		case strings.HasPrefix(s, "/*"):
+			_, s = split2(s, "*/")
+		case strings.HasPrefix(s, "@{"):
+			_, s = split2(s, "}")
+		default:
+			fields := strings.FieldsFunc(s, func(r rune) bool {
+				return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+			})
+			if len(fields) == 0 || !strings.HasPrefix(s, fields[0]) {
+				return ""
+			}
+			return strings.ToUpper(fields[0])
+		}
+		s = strings.TrimSpace(s)
+	}
+}
+
+// serverAddressAttribute returns server.address from the target of a gRPC connection like spanner.googleapis.com:443.
+func serverAddressAttribute(target string) attribute.KeyValue {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	return attribute.String("server.address", host)
+}
@@ -2,101 +2,1091 @@ package interceptor
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	plantotrace "github.com/apstndb/spannerotel/internal/plantotrace"
+	"github.com/apstndb/spannerotel/internal/statkey"
+	"github.com/apstndb/spannerotel/internal/version"
 	"google.golang.org/grpc/metadata"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
 	"google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
+// Version is the version of spannerotel recorded as the instrumentation version of spans.
+const Version = version.Version
+
+const instrumentationName = "github.com/apstndb/spannerotel/interceptor"
+
+func (o *interceptorOption) tracer() trace.Tracer {
+	tp := o.tracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(Version), trace.WithSchemaURL(semconv.SchemaURL))
+}
+
 type interceptorOption struct {
-	statsSpanDecorators  []StatsSpanDecorator
-	headerSpanDecorators []HeaderSpanDecorator
+	statsSpanDecorators             []StatsSpanDecorator
+	headerSpanDecorators            []HeaderSpanDecorator
+	trailerSpanDecorators           []TrailerSpanDecorator
+	postSpanDecorators              []PostSpanDecorator
+	resultSetMetadataSpanDecorators []ResultSetMetadataSpanDecorator
+	requestSpanDecorators           []RequestSpanDecorator
+	planOptions                     []plantotrace.Option
+	planRootAttributeKeys           []attribute.Key
+	queryFilter                     func(sql string) bool
+	filterStatsDecorators           bool
+	partialResultSetCount           bool
+	rpcSemanticConventions          bool
+	maxAttributeValueLen            int
+	clock                           func() time.Time
+	clientOverhead                  bool
+	strictParsing                   bool
+	txnRetryTracker                 *txnRetryTracker
+	parentSpanContextKey            interface{}
+	traceSpanBudget                 int
+	traceSpanCounter                *boundedCounter
+	planSampler                     *planSampler
+	keyStyle                        statkey.Style
+	bytesReturned                   bool
+	operation                       bool
+	staticAttributes                []attribute.KeyValue
+	openCensusBridge                bool
+	transactionSpans                bool
+	tracerProvider                  trace.TracerProvider
+	dbSemanticConventions           bool
+	methodFilter                    func(method string) bool
+	errorStatus                     bool
+	createSpan                      bool
+	timeToFirstRow                  bool
+	bytesReceived                   bool
+	emittedPlans                    *boundedCounter
+	queryMode                       bool
+	autoRequestTag                  bool
+	baggageTagKeys                  []string
 }
 
 type Option func(*interceptorOption)
 
 func WithDefaultDecorators() Option {
 	return func(option *interceptorOption) {
-		WithStatsSpanDecorators(queryTextSpanDecorator, elapsedTimeSpanDecorator)(option)
-		WithHeaderSpanDecorators(gfeServerTimingSpanDecorator)(option)
+		WithStatsSpanDecorators(queryTextSpanDecorator, elapsedTimeSpanDecorator, statsSampledSpanDecorator)(option)
+		WithHeaderSpanDecorators(gfeServerTimingSpanDecorator, requestIDSpanDecorator)(option)
+		WithRequestSpanDecorators(readRequestSpanDecorator)(option)
 	}
 }
 
+// WithStatsSpanDecorators adds decorators which run when stats are received.
+// Stats decorators run in registration order, before header decorators.
 func WithStatsSpanDecorators(decorators ...StatsSpanDecorator) Option {
 	return func(o *interceptorOption) {
 		o.statsSpanDecorators = append(o.statsSpanDecorators, decorators...)
 	}
 }
 
+// WithHeaderSpanDecorators adds decorators which run when headers are available.
+// Header decorators run in registration order, after stats decorators.
 func WithHeaderSpanDecorators(decorators ...HeaderSpanDecorator) Option {
 	return func(o *interceptorOption) {
 		o.headerSpanDecorators = append(o.headerSpanDecorators, decorators...)
 	}
 }
 
-func StreamInterceptor(opts ...Option) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	var o interceptorOption
+// WithTrailerSpanDecorators adds decorators which run once when the RPC completes and trailers are available,
+// e.g. to record server-timing or status metadata only sent in trailers.
+func WithTrailerSpanDecorators(decorators ...TrailerSpanDecorator) Option {
+	return func(o *interceptorOption) {
+		o.trailerSpanDecorators = append(o.trailerSpanDecorators, decorators...)
+	}
+}
+
+// WithResultSetMetadataSpanDecorators adds decorators which run when ResultSetMetadata is received.
+// Result set metadata decorators run in registration order, before stats decorators.
+func WithResultSetMetadataSpanDecorators(decorators ...ResultSetMetadataSpanDecorator) Option {
+	return func(o *interceptorOption) {
+		o.resultSetMetadataSpanDecorators = append(o.resultSetMetadataSpanDecorators, decorators...)
+	}
+}
+
+// WithRequestSpanDecorators adds decorators which run when a request message is sent,
+// e.g. ExecuteSqlRequest for ExecuteStreamingSql and CommitRequest for Commit.
+// Request decorators run in registration order.
+func WithRequestSpanDecorators(decorators ...RequestSpanDecorator) Option {
+	return func(o *interceptorOption) {
+		o.requestSpanDecorators = append(o.requestSpanDecorators, decorators...)
+	}
+}
+
+// WithPostDecorators adds decorators which are guaranteed to run after all stats and header decorators,
+// e.g. for filtering or redaction of attributes set by them. Post decorators run in registration order.
+func WithPostDecorators(decorators ...PostSpanDecorator) Option {
+	return func(o *interceptorOption) {
+		o.postSpanDecorators = append(o.postSpanDecorators, decorators...)
+	}
+}
+
+// WithHiddenMetadataFields hides the given plan node metadata fields from plan span names.
+// call_type, iterator_type, scan_type and subquery_cluster_node are hidden by default.
+func WithHiddenMetadataFields(fields ...string) Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithHiddenMetadataFields(fields...))
+	}
+}
+
+// WithVisibleMetadataFields shows the given plan node metadata fields in plan span names
+// even if they are hidden by default.
+func WithVisibleMetadataFields(fields ...string) Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithVisibleMetadataFields(fields...))
+	}
+}
+
+// WithExecutionSummaryAttributes sets scalar fields of execution_summary of each plan node other than timestamps,
+// e.g. num_executions, as execution_summary.* attributes on plan node spans.
+func WithExecutionSummaryAttributes() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithExecutionSummaryAttributes())
+	}
+}
+
+// WithRootSpanSummary appends the first scan target in the plan to the root plan span name
+// so that the root span is identifiable in a crowded trace list.
+func WithRootSpanSummary() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithRootSpanSummary())
+	}
+}
+
+// WithWaitTime sets latency_ms, cpu_time_ms and wait_time_ms, which is latency minus CPU time,
+// on plan node spans to highlight operators waiting on IO or locks.
+func WithWaitTime() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithWaitTime())
+	}
+}
+
+// WithPlanRootMinimal makes the root plan span carry only its title and index
+// to avoid duplicating descriptive attributes of the RPC span.
+// It takes precedence over WithPlanRootAttributes, so attributes like query_text are kept only on the RPC span.
+func WithPlanRootMinimal() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithPlanRootMinimal())
+	}
+}
+
+// WithReferencedTables sets spanner.tables and spanner.indexes, the distinct tables and indexes scanned by the query,
+// on the root plan span. Spanner doesn't report the base table of index scans, so they are recorded separately.
+// spanner.index_seeks and spanner.table_scans, the numbers of scans with a seek condition and full table scans, are also set.
+func WithReferencedTables() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithReferencedTables())
+	}
+}
+
+// WithLinkLabelInSpanName controls whether the "[link type] " prefix appears in plan span names. The default is true.
+// If it is false, the link type is set as child_link_type attribute instead.
+func WithLinkLabelInSpanName(enabled bool) Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithLinkLabelInSpanName(enabled))
+	}
+}
+
+// WithPlanParentSpan creates a synthetic "query plan" span under the RPC span and roots all plan spans under it
+// to separate the RPC from the plan breakdown in the trace tree.
+func WithPlanParentSpan() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithPlanParentSpan())
+	}
+}
+
+// WithPlanAsSpanEvents records each visible plan node as an event of the RPC span instead of a nested span.
+// It is lighter for large plans, but the nesting and timing hierarchy of nodes is lost.
+// Options for plan spans, e.g. WithPlanParentSpan, are ignored when it is enabled.
+func WithPlanAsSpanEvents() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithPlanAsSpanEvents())
+	}
+}
+
+// WithCorrelatedSubqueryDetection sets spanner.has_correlated_subquery on the root plan span
+// if the plan contains a subquery node (display name ending with "Subquery") in the "Map" side of an Apply operator,
+// which is how Spanner executes correlated subqueries.
+func WithCorrelatedSubqueryDetection() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithCorrelatedSubqueryDetection())
+	}
+}
+
+// WithSkipZeroDurationNodes omits plan spans of nodes whose execution start and end timestamps are identical.
+// Their descendants are still emitted under the nearest emitted ancestor.
+func WithSkipZeroDurationNodes() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithSkipZeroDurationNodes())
+	}
+}
+
+// WithParentSpanFromContextKey makes plan spans attach to the trace.Span stored in the context under key
+// instead of the innermost span of the context, e.g. to skip incidental wrapper spans.
+// A span set by ContextWithPlanParentSpan takes precedence, and the innermost span is used if neither is found.
+// Stats decorators still decorate the innermost span.
+func WithParentSpanFromContextKey(key interface{}) Option {
+	return func(o *interceptorOption) {
+		o.parentSpanContextKey = key
+	}
+}
+
+type planParentSpanKey struct{}
+
+// ContextWithPlanParentSpan returns a context in which plan spans attach to span instead of the innermost span.
+func ContextWithPlanParentSpan(ctx context.Context, span trace.Span) context.Context {
+	return context.WithValue(ctx, planParentSpanKey{}, span)
+}
+
+type planTracingKey struct{}
+
+// ContextWithPlanTracing returns a context in which plan tracing is enabled or disabled for calls made with it,
+// e.g. to disable it for hot-path queries while keeping it for analytical ones with the same client.
+// It takes precedence over the query filter: if disabled, neither stats decorators nor plan spans are applied,
+// and if enabled, they are applied even if the query filter rejects the query.
+// The per-trace span budget and the plan sampler still apply to plan spans.
+func ContextWithPlanTracing(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, planTracingKey{}, enabled)
+}
+
+func planTracingFromContext(ctx context.Context) (enabled, ok bool) {
+	enabled, ok = ctx.Value(planTracingKey{}).(bool)
+	return enabled, ok
+}
+
+// WithPerTraceSpanBudget limits the number of plan spans emitted per trace to n across multiple queries.
+// A plan which would exceed the budget is not emitted, and plan.budget_exceeded is set on the RPC span instead.
+// Plan span counts of at most 1024 recent traces are tracked; the oldest trace is evicted when full,
+// so the budget of a long-running trace can be reset after eviction.
+func WithPerTraceSpanBudget(n int) Option {
+	return func(o *interceptorOption) {
+		o.traceSpanBudget = n
+		o.traceSpanCounter = newBoundedCounter(defaultMaxTrackedTraces)
+	}
+}
+
+// WithResumeDeduplication processes stats and plans of a logical query only once
+// even if a stream resumes the query after a transient error and they are delivered again.
+// Duplicated stats are skipped and plan.deduplicated is set on the span instead.
+// A logical query is identified by its transaction ID and ExecuteSqlRequest.seqno,
+// so single-use queries and reads, which don't have them, are not deduplicated.
+// At most 1024 recent queries are tracked.
+func WithResumeDeduplication() Option {
+	return func(o *interceptorOption) {
+		o.emittedPlans = newBoundedCounter(defaultMaxTrackedQueries)
+	}
+}
+
+// WithCompactNodeTitles uses only the operator part of node titles, e.g. "Table Scan", as plan span names
+// to keep them short, and sets metadata fields as metadata.* attributes instead.
+func WithCompactNodeTitles() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithCompactNodeTitles())
+	}
+}
+
+// WithRowEstimates sets rows.estimated, rows.actual and rows.estimation_error on plan node spans
+// when both the optimizer's estimated rows in plan metadata and the actual rows in PROFILE execution stats are available.
+func WithRowEstimates() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithRowEstimates())
+	}
+}
+
+// WithConditionSubqueries sets <link type>.subqueries attributes on plan spans alongside condition attributes,
+// e.g. "Residual Condition", which resolve scalar subquery variables referenced by the condition to plan nodes.
+func WithConditionSubqueries() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithConditionSubqueries())
+	}
+}
+
+// WithCollapseIdenticalSiblings emits one plan span for identical sibling subtrees, e.g. a union over repeated scans,
+// with collapsed_count and the rows and latency summed over the collapsed siblings.
+func WithCollapseIdenticalSiblings() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithCollapseIdenticalSiblings())
+	}
+}
+
+// WithCriticalPath sets spanner.critical_path, e.g. "0>3>7", and spanner.critical_path_ms on the root plan span,
+// which is the root-to-leaf chain of plan nodes with the greatest sum of latency in PROFILE execution stats.
+func WithCriticalPath() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithCriticalPath())
+	}
+}
+
+// WithAttributes sets attrs on every span decorated or created by this package, i.e. RPC spans and plan spans,
+// e.g. a team, a service tier or a database alias.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(o *interceptorOption) {
+		o.staticAttributes = append(o.staticAttributes, attrs...)
+		o.planOptions = append(o.planOptions, plantotrace.WithSpanStartOptions(trace.WithAttributes(attrs...)))
+	}
+}
+
+// WithClientName sets spanner.client_name on RPC spans to tell which application issued the call
+// when multiple services share a database.
+func WithClientName(name string) Option {
+	return func(o *interceptorOption) {
+		o.staticAttributes = append(o.staticAttributes, attribute.String("spanner.client_name", name))
+	}
+}
+
+// WithClientVersion sets spanner.client_version on RPC spans. It is the version of the application, not of this package.
+func WithClientVersion(version string) Option {
+	return func(o *interceptorOption) {
+		o.staticAttributes = append(o.staticAttributes, attribute.String("spanner.client_version", version))
+	}
+}
+
+// WithTextPlanAttribute sets spanner.text_plan, the plan tree rendered as indented text, on the root plan span.
+// It is truncated by WithMaxAttributeValueLen.
+func WithTextPlanAttribute() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithTextPlanAttribute())
+	}
+}
+
+// WithTracerProvider creates spans, including plan spans, by tp instead of the global TracerProvider.
+// It is useful for applications with multiple TracerProviders and tests with in-memory providers.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *interceptorOption) {
+		o.tracerProvider = tp
+		o.planOptions = append(o.planOptions, plantotrace.WithTracerProvider(tp))
+	}
+}
+
+// WithPlanSpanStartOptions passes opts, e.g. trace.WithLinks or trace.WithSpanKind, when plan node spans are started.
+// The start timestamps of plan spans are still taken from execution stats.
+func WithPlanSpanStartOptions(opts ...trace.SpanStartOption) Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithSpanStartOptions(opts...))
+	}
+}
+
+// WithoutIndexPrefix drops the zero-padded "<index>: " prefix from plan span names.
+// The index is still set as the index attribute.
+func WithoutIndexPrefix() Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithoutIndexPrefix())
+	}
+}
+
+// PlanNodeSummary is a structured summary of a visible plan node passed to a plan post processor.
+type PlanNodeSummary = plantotrace.NodeSummary
+
+// WithPlanPostProcessor sets a function which is called with the root plan span and summaries of visible plan nodes
+// after all plan spans are created, but before the root plan span ends, so it can set derived attributes on the root span.
+func WithPlanPostProcessor(postProcessor func(ctx context.Context, root trace.Span, summaries []PlanNodeSummary)) Option {
+	return func(o *interceptorOption) {
+		o.planOptions = append(o.planOptions, plantotrace.WithPostProcessor(postProcessor))
+	}
+}
+
+// WithPlanRootAttributes copies the attributes with the given keys, which are set on the RPC span by stats decorators,
+// onto the root plan node span so that plan spans are self-describing.
+func WithPlanRootAttributes(keys ...attribute.Key) Option {
+	return func(o *interceptorOption) {
+		o.planRootAttributeKeys = append(o.planRootAttributeKeys, keys...)
+	}
+}
+
+// WithQueryFilter limits plan span generation to queries whose query_text in stats satisfies filter.
+// Queries without query_text in stats are passed to filter as an empty string.
+// No sampling decision is made by the filter, so it only affects spans which are already sampled.
+func WithQueryFilter(filter func(sql string) bool) Option {
+	return func(o *interceptorOption) {
+		o.queryFilter = filter
+	}
+}
+
+// WithQueryFilterForStatsDecorators makes the filter of WithQueryFilter also skip stats decorators.
+func WithQueryFilterForStatsDecorators() Option {
+	return func(o *interceptorOption) {
+		o.filterStatsDecorators = true
+	}
+}
+
+func (o *interceptorOption) allowQuery(stats *spanner.ResultSetStats) bool {
+	if o.queryFilter == nil {
+		return true
+	}
+	return o.queryFilter(stats.GetQueryStats().GetFields()["query_text"].GetStringValue())
+}
+
+// WithScanEfficiency sets spanner.scan_efficiency, which is rows_returned / rows_scanned, on the span.
+// A low value is a signal of a missing index or poor predicate pushdown.
+func WithScanEfficiency() Option {
+	return WithStatsSpanDecorators(scanEfficiencySpanDecorator)
+}
+
+// WithReadTimestamp sets spanner.read_timestamp from the transaction in ResultSetMetadata if it is returned.
+func WithReadTimestamp() Option {
+	return WithResultSetMetadataSpanDecorators(readTimestampSpanDecorator)
+}
+
+// WithQueryHints sets spanner.has_query_hint and spanner.query_hints from the statement hint, like @{USE_ADDITIONAL_PARALLELISM=TRUE},
+// at the beginning of query_text in stats. Table and join hints are not detected.
+func WithQueryHints() Option {
+	return WithStatsSpanDecorators(queryHintsSpanDecorator)
+}
+
+// WithBackendVersion sets spanner.backend_version from the first non-empty value of backendVersionHeaders
+// in the response header. Spanner doesn't document these headers, so nothing is set when none of them are found.
+func WithBackendVersion() Option {
+	return WithHeaderSpanDecorators(backendVersionSpanDecorator)
+}
+
+// WithCacheHit sets spanner.cache_hit from the "cache" extra of server-timing metrics, e.g. "gfet4t7; dur=1; cache=hit".
+// "hit" and "true" are interpreted as true, and "miss" and "false" as false; other values are ignored.
+// Availability and semantics of the extra vary, so nothing is set when it is absent.
+func WithCacheHit() Option {
+	return WithHeaderSpanDecorators(cacheHitSpanDecorator)
+}
+
+// WithServedRegion sets spanner.served_region from server-timing extras.
+// It looks for region, location and loc keys of all server-timing metrics in this order.
+// Availability of these keys varies, so nothing is set if none of them are found.
+func WithServedRegion() Option {
+	return WithHeaderSpanDecorators(servedRegionSpanDecorator)
+}
+
+// WithRouteToLeader sets spanner.route_to_leader from x-goog-spanner-route-to-leader, which clients with leader aware routing
+// send for requests which should be served by the leader region, e.g. read-write transactions.
+// It is read from the outgoing metadata, or from the response header as a fallback.
+// Nothing is set if the header is absent or is not a boolean.
+func WithRouteToLeader() Option {
+	return WithHeaderSpanDecorators(routeToLeaderSpanDecorator)
+}
+
+// WithCreateSpan starts a client span for each RPC, named like google.spanner.v1.Spanner/ExecuteStreamingSql,
+// and decorates it instead of the span in the context. The span of a stream ends when RecvMsg returns io.EOF or an error,
+// or when the context of the stream is done, e.g. by RowIterator.Stop,
+// so its duration is the client-side stream duration separate from the caller's span.
+func WithCreateSpan() Option {
+	return func(o *interceptorOption) {
+		o.createSpan = true
+	}
+}
+
+// WithErrorStatus marks the span of an RPC which ends with a non-OK status as an error:
+// it sets rpc.grpc.status_code, records the error as an exception event, and sets the span status to Error.
+func WithErrorStatus() Option {
+	return func(o *interceptorOption) {
+		o.errorStatus = true
+	}
+}
+
+// recordError marks sp as an error by err if WithErrorStatus is set. nil err is ignored.
+func (o *interceptorOption) recordError(sp trace.Span, err error) {
+	if !o.errorStatus || err == nil {
+		return
+	}
+	sp.SetAttributes(rpcStatusCodeAttribute(err))
+	sp.RecordError(err)
+	sp.SetStatus(codes.Error, err.Error())
+}
+
+// WithMethodFilter limits instrumentation to RPCs whose full method name, e.g. /google.spanner.v1.Spanner/ExecuteStreamingSql,
+// satisfies filter. Other RPCs are passed through without wrapping streams or decorating spans.
+func WithMethodFilter(filter func(method string) bool) Option {
+	return func(o *interceptorOption) {
+		o.methodFilter = filter
+	}
+}
+
+// allowMethod reports whether the RPC of method is instrumented.
+func (o *interceptorOption) allowMethod(method string) bool {
+	return o.methodFilter == nil || o.methodFilter(method)
+}
+
+// WithRPCSemanticConventions sets attributes of the OpenTelemetry RPC semantic conventions,
+// rpc.system, rpc.service, rpc.method, and rpc.grpc.status_code when the RPC ends.
+func WithRPCSemanticConventions() Option {
+	return func(o *interceptorOption) {
+		o.rpcSemanticConventions = true
+	}
+}
+
+// WithMaxAttributeValueLen truncates string attributes set by decorators, plan span names and plan span attributes
+// longer than n bytes with an ellipsis, and sets "<key>.truncated" attributes for truncated attributes.
+// n <= 0 means no limit, which is the default.
+// Note that Cloud Trace limits attribute values to 256 bytes and span names to 128 bytes.
+func WithMaxAttributeValueLen(n int) Option {
+	return func(o *interceptorOption) {
+		o.maxAttributeValueLen = n
+		o.planOptions = append(o.planOptions, plantotrace.WithMaxAttributeValueLen(n))
+	}
+}
+
+// WithClock sets the clock used for timestamps which are not reported by Spanner. The default is time.Now.
+// It is mainly useful for deterministic tests.
+func WithClock(clock func() time.Time) Option {
+	return func(o *interceptorOption) {
+		o.clock = clock
+		o.planOptions = append(o.planOptions, plantotrace.WithClock(clock))
+	}
+}
+
+// WithClientOverhead sets spanner.client_overhead_ms, the RPC duration observed by the interceptor minus elapsed_time
+// in query stats, on the span when the RPC ends if it is positive.
+// The interceptor doesn't know the start of the span in the context, so the duration is measured from the stream creation.
+// It requires elapsed_time in query stats, so nothing is set for queries without stats.
+func WithClientOverhead() Option {
+	return func(o *interceptorOption) {
+		o.clientOverhead = true
+	}
+}
+
+// WithStrictParsing reports stats which can't be parsed, e.g. malformed elapsed_time or execution timestamps,
+// to the OpenTelemetry error handler (see otel.SetErrorHandler) instead of silently dropping them.
+// It is intended for CI or staging environments to detect changes of the Spanner output format.
+func WithStrictParsing() Option {
+	return func(o *interceptorOption) {
+		o.strictParsing = true
+		o.planOptions = append(o.planOptions, plantotrace.WithStrictParsing())
+	}
+}
+
+// WithTransactionRetryTracking counts RPCs in each transaction which failed with a status retried by the client
+// (UNAVAILABLE or RESOURCE_EXHAUSTED), and sets spanner.txn_retry_attempts on the span of Commit.
+// Transactions are correlated by the transaction id in requests and ResultSetMetadata.
+// At most maxTransactions transactions (1024 if maxTransactions <= 0) are tracked and the oldest one is evicted when full.
+// Transactions are also evicted on Commit or Rollback. The tracker is safe for concurrent use and shared by
+// the stream and unary interceptors of the same Interceptors.
+func WithTransactionRetryTracking(maxTransactions int) Option {
+	return func(o *interceptorOption) {
+		o.txnRetryTracker = newTxnRetryTracker(maxTransactions)
+	}
+}
+
+// WithBytesReturned sets spanner.bytes_returned on the span when the RPC ends.
+// bytes_returned in query stats is used if it is available, because it is reported by Spanner.
+// Otherwise, the serialized sizes of received PartialResultSet or ResultSet messages are accumulated,
+// which costs an extra size computation per message.
+func WithBytesReturned() Option {
+	return func(o *interceptorOption) {
+		o.bytesReturned = true
+	}
+}
+
+// WithOperation sets spanner.operation, the operation class like query.read_only, dml.read_write or read.single_use,
+// on the span when the RPC ends. The operation kind comes from the method and row counts in stats,
+// and the transaction type comes from the transaction selector of the request or the transaction in ResultSetMetadata.
+// The transaction type is omitted when it can't be determined, e.g. for a transaction selected by its id.
+func WithOperation() Option {
+	return func(o *interceptorOption) {
+		o.operation = true
+	}
+}
+
+// WithReceivedMessageStats sets spanner.partial_result_sets and spanner.bytes_received, the number and
+// the total serialized size of PartialResultSet messages received in the stream, on the span when the stream ends.
+// It includes WithPartialResultSetCount, so they can be used together.
+// Unlike WithBytesReturned, the size is always measured on the client, which costs an extra size computation per message;
+// it equals spanner.bytes_returned of WithBytesReturned if query stats have no bytes_returned, and the computation is shared.
+func WithReceivedMessageStats() Option {
+	return func(o *interceptorOption) {
+		WithPartialResultSetCount()(o)
+		o.bytesReceived = true
+	}
+}
+
+// WithTimeToFirstRow sets spanner.time_to_first_row_ms (in the configured key style), the duration
+// from the stream creation to the first PartialResultSet containing values, on the span.
+// Nothing is set for streams which return no rows.
+func WithTimeToFirstRow() Option {
+	return func(o *interceptorOption) {
+		o.timeToFirstRow = true
+	}
+}
+
+// WithPartialResultSetCount sets spanner.partial_result_sets, the number of PartialResultSet messages received
+// in the stream, on the span when the stream ends.
+func WithPartialResultSetCount() Option {
+	return func(o *interceptorOption) {
+		o.partialResultSetCount = true
+	}
+}
+
+// Interceptors bundles the stream and unary interceptors which share the same configuration.
+type Interceptors struct {
+	option interceptorOption
+}
+
+func New(opts ...Option) *Interceptors {
+	var i Interceptors
 	for _, option := range opts {
-		option(&o)
+		option(&i.option)
 	}
+	return &i
+}
+
+func (i *Interceptors) StreamInterceptor() grpc.StreamClientInterceptor {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !i.option.allowMethod(method) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		start := i.option.now()
+		var created trace.Span
+		if i.option.createSpan {
+			ctx, created = i.option.tracer().Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindClient))
+		}
+		i.option.setStaticAttributes(trace.SpanFromContext(ctx))
+		if i.option.dbSemanticConventions {
+			trace.SpanFromContext(ctx).SetAttributes(serverAddressAttribute(cc.Target()))
+		}
 		stream, err := streamer(ctx, desc, cc, method, opts...)
-		return &ClientStream{stream, ctx, method, desc, o.statsSpanDecorators, o.headerSpanDecorators}, err
+		i.option.recordError(trace.SpanFromContext(ctx), err)
+		if i.option.rpcSemanticConventions {
+			sp := trace.SpanFromContext(ctx)
+			sp.SetAttributes(rpcAttributes(method)...)
+			if err != nil {
+				sp.SetAttributes(rpcStatusCodeAttribute(err))
+			}
+		}
+		if created != nil && err != nil {
+			created.End()
+			created = nil
+		}
+		cs := &ClientStream{ClientStream: stream, ctx: ctx, method: method, desc: desc, option: &i.option, start: start, span: created, spanEnded: make(chan struct{})}
+		cs.endSpanOnDone(ctx)
+		return cs, err
+	}
+}
+
+func StreamInterceptor(opts ...Option) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return New(opts...).StreamInterceptor()
+}
+
+func UnaryInterceptor(opts ...Option) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return New(opts...).UnaryInterceptor()
+}
+
+// ClientOptions returns client options which install both the stream and unary interceptors sharing the same configuration.
+func (i *Interceptors) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(i.StreamInterceptor())),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(i.UnaryInterceptor())),
 	}
 }
 
+// ClientOptions is a shorthand of New(opts...).ClientOptions(), e.g.
+//
+//	spanner.NewClientWithConfig(ctx, database, config, interceptor.ClientOptions(interceptor.WithDefaultDecorators())...)
+func ClientOptions(opts ...Option) []option.ClientOption {
+	return New(opts...).ClientOptions()
+}
+
 type ClientStream struct {
 	grpc.ClientStream
-	ctx                  context.Context
-	method               string
-	desc                 *grpc.StreamDesc
-	statsSpanDecorators  []StatsSpanDecorator
-	headerSpanDecorators []HeaderSpanDecorator
+	ctx    context.Context
+	method string
+	desc   *grpc.StreamDesc
+	option *interceptorOption
+
+	start             time.Time
+	partialResultSets int
+	elapsedTimeMs     float64
+	hasElapsedTime    bool
+	transactionID     []byte
+	receivedBytes     int64
+	statsBytes        int64
+	hasStatsBytes     bool
+	transactionType   string
+	isDML             bool
+	firstRowReceived  bool
+	headerDecorated   bool
+	// resumed is true if the request resumes a query by a resume token, and seqno is the sequence number of the request.
+	resumed bool
+	seqno   int64
+	// request is the last sent request.
+	request interface{}
+	// span is the span created by WithCreateSpan, which is ended when the stream ends or its context is done.
+	span        trace.Span
+	endSpanOnce sync.Once
+	spanEnded   chan struct{}
+}
+
+// defaultMaxTrackedQueries is the number of recent queries tracked by WithResumeDeduplication.
+const defaultMaxTrackedQueries = 1024
+
+// isDuplicatedStats reports whether stats of the logical query have already been processed,
+// i.e. the stream resumes the query after a transient error and the stats are delivered again.
+// Otherwise, it records that the stats of the query are processed.
+// A logical query is identified by its transaction ID and sequence number,
+// so queries without them, e.g. single-use queries and reads, are never deduplicated.
+func (l *ClientStream) isDuplicatedStats() bool {
+	if l.option.emittedPlans == nil || len(l.transactionID) == 0 || l.seqno == 0 {
+		return false
+	}
+	key := hex.EncodeToString(l.transactionID) + "/" + strconv.FormatInt(l.seqno, 10)
+	if l.resumed && l.option.emittedPlans.get(key) > 0 {
+		return true
+	}
+	l.option.emittedPlans.add(key, 1)
+	return false
+}
+
+// endSpan ends the span created by WithCreateSpan if any. It is safe to call it multiple times concurrently.
+func (l *ClientStream) endSpan() {
+	if l.span == nil {
+		return
+	}
+	l.endSpanOnce.Do(func() {
+		l.span.End()
+		close(l.spanEnded)
+	})
+}
+
+// endSpanOnDone ends the span created by WithCreateSpan when ctx is done,
+// because a stream can be cancelled without RecvMsg returning, e.g. by RowIterator.Stop.
+func (l *ClientStream) endSpanOnDone(ctx context.Context) {
+	if l.span == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.endSpan()
+		case <-l.spanEnded:
+		}
+	}()
+}
+
+func (l *ClientStream) SendMsg(m interface{}) error {
+	if id := requestTransactionID(m); len(id) > 0 {
+		l.transactionID = id
+	}
+	if t := requestTransactionType(m); t != "" {
+		l.transactionType = t
+	}
+	l.request = m
+	if r, ok := m.(interface{ GetResumeToken() []byte }); ok {
+		l.resumed = len(r.GetResumeToken()) > 0
+	}
+	if r, ok := m.(*spanner.ExecuteSqlRequest); ok {
+		l.seqno = r.GetSeqno()
+	}
+	ctx := contextWithMethod(l.ClientStream.Context(), l.method)
+	l.option.tagRequest(ctx, m)
+	l.option.decorateRequest(ctx, l.option.spanFromContext(ctx), m)
+	return l.ClientStream.SendMsg(m)
 }
 
 func (l *ClientStream) RecvMsg(m interface{}) error {
 	err := l.ClientStream.RecvMsg(m)
 	if err != nil && err != io.EOF {
+		if l.option.txnRetryTracker != nil {
+			l.option.txnRetryTracker.observe(l.transactionID, err)
+		}
+		if l.option.rpcSemanticConventions {
+			trace.SpanFromContext(l.ClientStream.Context()).SetAttributes(rpcStatusCodeAttribute(err))
+		}
+		l.option.recordError(trace.SpanFromContext(l.ClientStream.Context()), err)
+		if len(l.option.trailerSpanDecorators) > 0 {
+			ctx := contextWithMethod(l.ClientStream.Context(), l.method)
+			l.option.decorateTrailer(ctx, l.option.spanFromContext(ctx), l.ClientStream.Trailer())
+		}
+		l.endSpan()
 		return err
 	}
 
-	ctx := l.ClientStream.Context()
-	sp := trace.SpanFromContext(ctx)
+	ctx := contextWithQueryMode(contextWithMethod(l.ClientStream.Context(), l.method), l.request)
+	sp := l.option.spanFromContext(ctx)
+	if err == io.EOF && l.option.rpcSemanticConventions {
+		sp.SetAttributes(rpcStatusCodeAttribute(nil))
+	}
+	if err == io.EOF && l.option.partialResultSetCount {
+		sp.SetAttributes(attribute.Int("spanner.partial_result_sets", l.partialResultSets))
+	}
+	if err == io.EOF && l.option.clientOverhead && l.hasElapsedTime {
+		l.option.setClientOverhead(sp, l.start, l.elapsedTimeMs)
+	}
+	if err == io.EOF && l.option.operation {
+		sp.SetAttributes(attribute.String("spanner.operation", operationName(operationKind(l.method, l.isDML), l.transactionType)))
+	}
+	if err == io.EOF && l.option.bytesReceived {
+		sp.SetAttributes(attribute.Int64("spanner.bytes_received", l.receivedBytes))
+	}
+	if err == io.EOF && l.option.bytesReturned {
+		if l.hasStatsBytes {
+			sp.SetAttributes(attribute.Int64("spanner.bytes_returned", l.statsBytes))
+		} else {
+			sp.SetAttributes(attribute.Int64("spanner.bytes_returned", l.receivedBytes))
+		}
+	}
+
 	var stats *spanner.ResultSetStats
+	var resultSetMetadata *spanner.ResultSetMetadata
 	switch m := m.(type) {
 	case *spanner.PartialResultSet:
+		if err == nil {
+			l.partialResultSets++
+			if l.option.timeToFirstRow && !l.firstRowReceived && len(m.GetValues()) > 0 {
+				l.firstRowReceived = true
+				ms := float64(l.option.now().Sub(l.start)) / float64(time.Millisecond)
+				sp.SetAttributes(statkey.TimeToFirstRow.Format(l.option.keyStyle).Float64(ms))
+			}
+			if l.option.bytesReturned || l.option.bytesReceived {
+				l.receivedBytes += int64(proto.Size(m))
+			}
+		}
 		stats = m.GetStats()
+		resultSetMetadata = m.GetMetadata()
 	case *spanner.ResultSet:
 		stats = m.GetStats()
+		resultSetMetadata = m.GetMetadata()
+	}
+	if resultSetMetadata != nil {
+		if id := resultSetMetadata.GetTransaction().GetId(); len(id) > 0 {
+			l.transactionID = id
+		}
+		if t := metadataTransactionType(resultSetMetadata); l.transactionType == "" && t != "" {
+			l.transactionType = t
+		}
+		l.option.decorateResultSetMetadata(ctx, sp, resultSetMetadata)
 	}
 	if stats != nil {
-		for _, dec := range l.statsSpanDecorators {
-			dec(ctx, sp, stats)
+		if ms, ok := l.option.elapsedTimeMillis(stats); ok {
+			l.elapsedTimeMs, l.hasElapsedTime = ms, true
+		}
+		if n, ok := parseStatInt(queryStatsField(stats, "bytes_returned")); ok {
+			l.statsBytes, l.hasStatsBytes = n, true
+		}
+		if stats.GetRowCount() != nil {
+			l.isDML = true
+		}
+		if l.isDuplicatedStats() {
+			sp.SetAttributes(attribute.Bool("plan.deduplicated", true))
+		} else {
+			l.option.decorateStats(ctx, sp, stats)
 		}
-		plantotrace.Span(ctx, stats)
 	}
 
-	// don't override RecvMsg err
-	if md, err := l.ClientStream.Header(); err == nil {
-		// if md, _ := l.ClientStream.Header(); md.Len() > 0 {
-		for _, dec := range l.headerSpanDecorators {
-			dec(ctx, sp, md)
+	// Headers arrive once per stream, so they are decorated once when they are available.
+	if !l.headerDecorated {
+		// don't override RecvMsg err
+		if md, err := l.ClientStream.Header(); err == nil {
+			l.headerDecorated = true
+			l.option.decorateHeader(ctx, sp, md)
 		}
 	}
-	// }
 
+	if err == io.EOF {
+		l.option.decorateTrailer(ctx, sp, l.ClientStream.Trailer())
+	}
+
+	l.option.decoratePost(ctx, sp)
+
+	if err == io.EOF {
+		l.endSpan()
+	}
 	return err
 }
 
+func (o *interceptorOption) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock()
+}
+
+func (o *interceptorOption) handleParseError(err error) {
+	if o.strictParsing {
+		otel.Handle(fmt.Errorf("spannerotel: %w", err))
+	}
+}
+
+func (o *interceptorOption) elapsedTimeMillis(stats *spanner.ResultSetStats) (float64, bool) {
+	elapsedTime := queryStatsField(stats, "elapsed_time").GetStringValue()
+	if elapsedTime == "" {
+		return 0, false
+	}
+	ms, ok := parseStatMillis(elapsedTime)
+	if !ok {
+		o.handleParseError(fmt.Errorf("invalid elapsed_time %q", elapsedTime))
+	}
+	return ms, ok
+}
+
+func (o *interceptorOption) setClientOverhead(sp trace.Span, start time.Time, elapsedTimeMs float64) {
+	wallMs := float64(o.now().Sub(start)) / float64(time.Millisecond)
+	if overhead := wallMs - elapsedTimeMs; overhead > 0 {
+		sp.SetAttributes(statkey.ClientOverhead.Format(o.keyStyle).Float64(overhead))
+	}
+}
+
+// setStaticAttributes sets attributes which are the same for all calls, e.g. spanner.client_name.
+func (o *interceptorOption) setStaticAttributes(sp trace.Span) {
+	if len(o.staticAttributes) > 0 {
+		sp.SetAttributes(o.staticAttributes...)
+	}
+}
+
+func (o *interceptorOption) spanFromContext(ctx context.Context) trace.Span {
+	return plantotrace.TruncatingSpan(trace.SpanFromContext(ctx), o.maxAttributeValueLen)
+}
+
+func (o *interceptorOption) decorateRequest(ctx context.Context, sp trace.Span, req interface{}) {
+	for _, dec := range o.requestSpanDecorators {
+		dec(ctx, sp, req)
+	}
+}
+
+func (o *interceptorOption) decorateResultSetMetadata(ctx context.Context, sp trace.Span, resultSetMetadata *spanner.ResultSetMetadata) {
+	for _, dec := range o.resultSetMetadataSpanDecorators {
+		dec(ctx, sp, resultSetMetadata)
+	}
+}
+
+func (o *interceptorOption) decorateStats(ctx context.Context, sp trace.Span, stats *spanner.ResultSetStats) {
+	// Plan spans attach to the plan parent span if any, e.g. a span set by ContextWithPlanParentSpan or
+	// a remote span of OpenCensus, so it is resolved before the fast path.
+	parent, hasParent := o.planParentSpan(ctx)
+	planParent := sp
+	if hasParent {
+		planParent = parent
+	}
+	// Fast path for unsampled queries: attributes are dropped and plan spans are not sampled by parent-based samplers.
+	// A remote parent is never recording, so its sampled flag is also checked.
+	if !sp.IsRecording() && !planParent.IsRecording() && !planParent.SpanContext().IsSampled() {
+		return
+	}
+	allowed, ok := planTracingFromContext(ctx)
+	if ok && !allowed {
+		return
+	}
+	if !ok {
+		allowed = o.allowQuery(stats)
+	}
+	recorder := &attributeRecordingSpan{Span: sp}
+	if allowed || !o.filterStatsDecorators {
+		for _, dec := range o.statsSpanDecorators {
+			dec(ctx, recorder, stats)
+		}
+	}
+	if allowed && !o.samplePlan(sp, stats) {
+		allowed = false
+	}
+	if allowed && !o.consumeSpanBudget(sp, stats) {
+		sp.SetAttributes(attribute.Bool("plan.budget_exceeded", true))
+		allowed = false
+	}
+	if allowed {
+		planOptions := append([]plantotrace.Option{}, o.planOptions...)
+		planOptions = append(planOptions, plantotrace.WithRootAttributes(recorder.filter(o.planRootAttributeKeys)...))
+		if mode, ok := queryModeFromContext(ctx); ok && o.queryMode {
+			planOptions = append(planOptions, plantotrace.WithRootAttributes(queryModeAttribute(mode)))
+		}
+		if hasParent {
+			plantotrace.SpanWithParent(ctx, parent, stats, planOptions...)
+		} else {
+			plantotrace.Span(ctx, stats, planOptions...)
+		}
+	}
+}
+
+const defaultMaxTrackedTraces = 1024
+
+// consumeSpanBudget reports whether the plan spans of stats are within the per-trace budget, and consumes it if so.
+func (o *interceptorOption) consumeSpanBudget(sp trace.Span, stats *spanner.ResultSetStats) bool {
+	if o.traceSpanCounter == nil {
+		return true
+	}
+	key := sp.SpanContext().TraceID().String()
+	n := plantotrace.SpanCount(stats, o.planOptions...)
+	if o.traceSpanCounter.get(key)+n > o.traceSpanBudget {
+		return false
+	}
+	o.traceSpanCounter.add(key, n)
+	return true
+}
+
+func (o *interceptorOption) planParentSpan(ctx context.Context) (trace.Span, bool) {
+	if span, ok := ctx.Value(planParentSpanKey{}).(trace.Span); ok {
+		return span, true
+	}
+	if o.parentSpanContextKey != nil {
+		if span, ok := ctx.Value(o.parentSpanContextKey).(trace.Span); ok {
+			return span, true
+		}
+	}
+	if o.openCensusBridge {
+		return openCensusParentSpan(ctx)
+	}
+	return nil, false
+}
+
+func (o *interceptorOption) decorateHeader(ctx context.Context, sp trace.Span, md metadata.MD) {
+	for _, dec := range o.headerSpanDecorators {
+		dec(ctx, sp, md)
+	}
+}
+
+func (o *interceptorOption) decorateTrailer(ctx context.Context, sp trace.Span, md metadata.MD) {
+	for _, dec := range o.trailerSpanDecorators {
+		dec(ctx, sp, md)
+	}
+}
+
+func (o *interceptorOption) decoratePost(ctx context.Context, sp trace.Span) {
+	for _, dec := range o.postSpanDecorators {
+		dec(ctx, sp)
+	}
+}
+
+// attributeRecordingSpan records attributes set via SetAttributes because trace.Span doesn't expose them.
+type attributeRecordingSpan struct {
+	trace.Span
+	attributes []attribute.KeyValue
+}
+
+func (s *attributeRecordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.Span.SetAttributes(kv...)
+	s.attributes = append(s.attributes, kv...)
+}
+
+func (s *attributeRecordingSpan) filter(keys []attribute.Key) []attribute.KeyValue {
+	var result []attribute.KeyValue
+	for _, kv := range s.attributes {
+		for _, key := range keys {
+			if kv.Key == key {
+				result = append(result, kv)
+				break
+			}
+		}
+	}
+	return result
+}
+
 type StatsSpanDecorator func(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats)
 type HeaderSpanDecorator func(ctx context.Context, span trace.Span, header metadata.MD)
+type TrailerSpanDecorator func(ctx context.Context, span trace.Span, trailer metadata.MD)
+type PostSpanDecorator func(ctx context.Context, span trace.Span)
+type RequestSpanDecorator func(ctx context.Context, span trace.Span, req interface{})
+type ResultSetMetadataSpanDecorator func(ctx context.Context, span trace.Span, metadata *spanner.ResultSetMetadata)
 
 type serverTiming struct {
 	Name       string
@@ -113,18 +1103,38 @@ func split2(s, sep string) (head, rest string) {
 	}
 }
 
+// parseServerTimings parses a server-timing header value which may contain multiple comma-separated metrics.
+func parseServerTimings(raw string) []serverTiming {
+	var result []serverTiming
+	for _, metric := range strings.Split(raw, ",") {
+		if strings.TrimSpace(metric) == "" {
+			continue
+		}
+		result = append(result, parseServerTiming(metric))
+	}
+	return result
+}
+
+// parseServerTiming parses a server-timing metric like `gfet4t7; dur=123`.
+// It is best-effort for malformed input: an invalid or negative dur is ignored, and empty parameters are skipped.
+// A fractional dur is truncated to milliseconds.
 func parseServerTiming(raw string) serverTiming {
 	var duration int
-	name, rest := split2(raw, ";")
+	name, rest := split2(strings.TrimSpace(raw), ";")
+	name = strings.TrimSpace(name)
 	extra := make(map[string]string)
 	for _, v := range strings.Split(rest, ";") {
 		key, value := split2(strings.TrimSpace(v), "=")
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"`)
+		if key == "" {
+			continue
+		}
 		if strings.ToLower(key) == "dur" {
-			if d, err := strconv.Atoi(value); err != nil {
+			d, err := strconv.ParseFloat(value, 64)
+			if err != nil || d < 0 || d > math.MaxInt32 {
 				continue
-			} else {
-				duration = d
 			}
+			duration = int(d)
 		} else {
 			extra[key] = value
 		}
@@ -138,17 +1148,242 @@ func parseServerTiming(raw string) serverTiming {
 
 const gfeServerTimingName = "gfet4t7"
 
+const commitMethod = "/google.spanner.v1.Spanner/Commit"
+
+type methodKey struct{}
+
+func contextWithMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, methodKey{}, method)
+}
+
+// MethodFromContext returns the full gRPC method name, e.g. /google.spanner.v1.Spanner/ExecuteStreamingSql,
+// in the context passed to decorators.
+func MethodFromContext(ctx context.Context) string {
+	method, _ := ctx.Value(methodKey{}).(string)
+	return method
+}
+
+// methodAttributeKey prefixes key with "commit." for Commit so that timing of commits is not conflated with queries.
+func methodAttributeKey(ctx context.Context, key string) string {
+	if MethodFromContext(ctx) == commitMethod {
+		return "commit." + key
+	}
+	return key
+}
+
 func gfeServerTimingSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
 	for _, rawServerTiming := range header.Get("server-timing") {
-		if serverTiming := parseServerTiming(rawServerTiming); serverTiming.Name == gfeServerTimingName {
-			span.SetAttributes(attribute.Int("gfe-server-timing", serverTiming.DurationMs))
+		for _, serverTiming := range parseServerTimings(rawServerTiming) {
+			if serverTiming.Name == gfeServerTimingName {
+				span.SetAttributes(attribute.Int(methodAttributeKey(ctx, "gfe-server-timing"), serverTiming.DurationMs))
+			}
+		}
+	}
+}
+
+// WithServerTimingMetrics sets server-timing.<name> attributes with the durations of the named server-timing metrics,
+// e.g. WithServerTimingMetrics("afe", "gfet4t7"). They are prefixed with "commit." for Commit.
+// gfet4t7 is also recorded as gfe-server-timing by WithDefaultDecorators.
+func WithServerTimingMetrics(names ...string) Option {
+	return WithHeaderSpanDecorators(serverTimingMetricsSpanDecorator(names...))
+}
+
+func serverTimingMetricsSpanDecorator(names ...string) HeaderSpanDecorator {
+	return func(ctx context.Context, span trace.Span, header metadata.MD) {
+		for _, rawServerTiming := range header.Get("server-timing") {
+			for _, serverTiming := range parseServerTimings(rawServerTiming) {
+				for _, name := range names {
+					if serverTiming.Name == name {
+						span.SetAttributes(attribute.Int(methodAttributeKey(ctx, "server-timing."+name), serverTiming.DurationMs))
+					}
+				}
+			}
+		}
+	}
+}
+
+func readTimestampSpanDecorator(ctx context.Context, span trace.Span, metadata *spanner.ResultSetMetadata) {
+	if ts := metadata.GetTransaction().GetReadTimestamp(); ts != nil {
+		span.SetAttributes(attribute.String("spanner.read_timestamp", ts.AsTime().Format(time.RFC3339Nano)))
+	}
+}
+
+const requestIDHeader = "x-goog-spanner-request-id"
+
+// requestIDSpanDecorator sets spanner.request_id from x-goog-spanner-request-id sent by recent clients.
+// It is read from the outgoing metadata, or from the response header as a fallback.
+// Older clients don't send it, so nothing is set in that case.
+// Its components are also set as spanner.request_id.* if it is structured like
+// "<version>.<process>.<client>.<channel>.<request>.<attempt>", so retries and channels can be told apart.
+func requestIDSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
+	var ids []string
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		ids = md.Get(requestIDHeader)
+	}
+	if len(ids) == 0 {
+		ids = header.Get(requestIDHeader)
+	}
+	if len(ids) > 0 {
+		span.SetAttributes(attribute.String("spanner.request_id", ids[0]))
+		span.SetAttributes(requestIDAttributes(ids[0])...)
+	}
+}
+
+// requestIDAttributes returns attributes of the components of a structured request ID,
+// or nil if id is not structured.
+func requestIDAttributes(id string) []attribute.KeyValue {
+	parts := strings.Split(id, ".")
+	if len(parts) != 6 || parts[1] == "" {
+		return nil
+	}
+	var numbers [5]int64
+	for i, part := range []string{parts[0], parts[2], parts[3], parts[4], parts[5]} {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil
+		}
+		numbers[i] = n
+	}
+	return []attribute.KeyValue{
+		attribute.Int64("spanner.request_id.version", numbers[0]),
+		attribute.String("spanner.request_id.process", parts[1]),
+		attribute.Int64("spanner.request_id.client", numbers[1]),
+		attribute.Int64("spanner.request_id.channel", numbers[2]),
+		attribute.Int64("spanner.request_id.request", numbers[3]),
+		attribute.Int64("spanner.request_id.attempt", numbers[4]),
+	}
+}
+
+// backendVersionHeaders are response header keys probed by WithBackendVersion in this order.
+var backendVersionHeaders = []string{"x-goog-spanner-backend-version", "x-goog-backend-version", "x-goog-spanner-build"}
+
+func backendVersionSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
+	for _, key := range backendVersionHeaders {
+		if values := header.Get(key); len(values) > 0 && values[0] != "" {
+			span.SetAttributes(attribute.String("spanner.backend_version", values[0]))
+			return
+		}
+	}
+}
+
+func cacheHitSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
+	for _, rawServerTiming := range header.Get("server-timing") {
+		for _, serverTiming := range parseServerTimings(rawServerTiming) {
+			switch strings.ToLower(strings.Trim(serverTiming.Extra["cache"], `"`)) {
+			case "hit", "true":
+				span.SetAttributes(attribute.Bool("spanner.cache_hit", true))
+				return
+			case "miss", "false":
+				span.SetAttributes(attribute.Bool("spanner.cache_hit", false))
+				return
+			}
+		}
+	}
+}
+
+var servedRegionKeys = []string{"region", "location", "loc"}
+
+func servedRegionSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
+	for _, rawServerTiming := range header.Get("server-timing") {
+		for _, serverTiming := range parseServerTimings(rawServerTiming) {
+			for _, key := range servedRegionKeys {
+				if region := serverTiming.Extra[key]; region != "" {
+					span.SetAttributes(attribute.String("spanner.served_region", strings.Trim(region, `"`)))
+					return
+				}
+			}
 		}
 	}
 }
+
+const routeToLeaderHeader = "x-goog-spanner-route-to-leader"
+
+func routeToLeaderSpanDecorator(ctx context.Context, span trace.Span, header metadata.MD) {
+	var values []string
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		values = md.Get(routeToLeaderHeader)
+	}
+	if len(values) == 0 {
+		values = header.Get(routeToLeaderHeader)
+	}
+	if len(values) == 0 {
+		return
+	}
+	if b, err := strconv.ParseBool(values[0]); err == nil {
+		span.SetAttributes(attribute.Bool("spanner.route_to_leader", b))
+	}
+}
+
 func queryTextSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	// Reads don't have SQL text.
+	if isReadMethod(MethodFromContext(ctx)) {
+		return
+	}
 	span.SetAttributes(attribute.String("query_text", stats.GetQueryStats().GetFields()["query_text"].GetStringValue()))
 }
 
 func elapsedTimeSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
 	span.SetAttributes(attribute.String("elapsed_time", stats.GetQueryStats().GetFields()["elapsed_time"].GetStringValue()))
 }
+
+func scanEfficiencySpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	returned, ok := parseStatInt(queryStatsField(stats, "rows_returned"))
+	if !ok {
+		return
+	}
+	scanned, ok := parseStatInt(queryStatsField(stats, "rows_scanned"))
+	if !ok || scanned == 0 {
+		return
+	}
+	span.SetAttributes(attribute.Float64("spanner.scan_efficiency", float64(returned)/float64(scanned)))
+}
+
+// profileQueryStatsFields are query stats fields which are always returned in PROFILE mode.
+var profileQueryStatsFields = []string{"elapsed_time", "cpu_time", "rows_returned", "rows_scanned"}
+
+// statsSampledSpanDecorator sets spanner.stats_sampled if stats look partial.
+// Spanner doesn't return an explicit signal, so stats are considered partial if the query plan has execution stats,
+// which means PROFILE mode, but some of profileQueryStatsFields are absent.
+func statsSampledSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	var profiled bool
+	for _, node := range stats.GetQueryPlan().GetPlanNodes() {
+		if node.GetExecutionStats() != nil {
+			profiled = true
+			break
+		}
+	}
+	if !profiled {
+		return
+	}
+	for _, key := range profileQueryStatsFields {
+		if queryStatsField(stats, key) == nil {
+			span.SetAttributes(attribute.Bool("spanner.stats_sampled", true))
+			return
+		}
+	}
+}
+
+// parseStatementHint returns the content of the statement hint at the beginning of sql.
+func parseStatementHint(sql string) (string, bool) {
+	sql = strings.TrimSpace(sql)
+	if !strings.HasPrefix(sql, "@{") {
+		return "", false
+	}
+	end := strings.Index(sql, "}")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(sql[len("@{"):end]), true
+}
+
+func queryHintsSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	sql := queryStatsField(stats, "query_text").GetStringValue()
+	if sql == "" {
+		return
+	}
+	hints, ok := parseStatementHint(sql)
+	span.SetAttributes(attribute.Bool("spanner.has_query_hint", ok))
+	if ok {
+		span.SetAttributes(attribute.String("spanner.query_hints", hints))
+	}
+}
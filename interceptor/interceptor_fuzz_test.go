@@ -0,0 +1,36 @@
+//go:build go1.18
+
+package interceptor
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseServerTiming(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"gfet4t7; dur=123",
+		"gfet4t7; dur=1.5",
+		"gfet4t7; dur=-1",
+		"gfet4t7; dur=abc",
+		"gfet4t7; a=b=c",
+		`gfet4t7; desc="x"; ; =1`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		st := parseServerTiming(raw)
+		if st.DurationMs < 0 {
+			t.Errorf("parseServerTiming(%q).DurationMs = %v, want non-negative", raw, st.DurationMs)
+		}
+		if st.Name != strings.TrimSpace(st.Name) {
+			t.Errorf("parseServerTiming(%q).Name = %q, want trimmed", raw, st.Name)
+		}
+		for k := range st.Extra {
+			if k == "" || k != strings.TrimSpace(k) {
+				t.Errorf("parseServerTiming(%q).Extra has key %q, want non-empty and trimmed", raw, k)
+			}
+		}
+	})
+}
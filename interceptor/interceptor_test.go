@@ -0,0 +1,786 @@
+package interceptor
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	executeStreamingSQLMethod = "/google.spanner.v1.Spanner/ExecuteStreamingSql"
+	executeSQLMethod          = "/google.spanner.v1.Spanner/ExecuteSql"
+)
+
+// fakeClientStream is a grpc.ClientStream which returns responses in order, and then err or io.EOF.
+type fakeClientStream struct {
+	ctx       context.Context
+	header    metadata.MD
+	trailer   metadata.MD
+	responses []proto.Message
+	err       error
+	sent      []interface{}
+}
+
+func (s *fakeClientStream) Header() (metadata.MD, error) { return s.header, nil }
+func (s *fakeClientStream) Trailer() metadata.MD         { return s.trailer }
+func (s *fakeClientStream) CloseSend() error             { return nil }
+func (s *fakeClientStream) Context() context.Context     { return s.ctx }
+
+func (s *fakeClientStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return s.err
+		}
+		return io.EOF
+	}
+	proto.Reset(m.(proto.Message))
+	proto.Merge(m.(proto.Message), s.responses[0])
+	s.responses = s.responses[1:]
+	return nil
+}
+
+// streamResult is the result of runStream.
+type streamResult struct {
+	// spans are ended spans except rpc, e.g. plan spans.
+	spans []sdktrace.ReadOnlySpan
+	// rpc is the span in the context of the RPC.
+	rpc sdktrace.ReadOnlySpan
+	// err is the error which ends the stream other than io.EOF.
+	err error
+}
+
+// runStream sends req to a stream intercepted with opts, and receives all responses of fake.
+func runStream(t testing.TB, method string, req proto.Message, fake *fakeClientStream, opts ...Option) streamResult {
+	t.Helper()
+	return runStreamInContext(t, nil, method, req, fake, opts...)
+}
+
+// runStreamInContext is like runStream, but the RPC span is started in the context returned by setup if it is not nil.
+func runStreamInContext(t testing.TB, setup func(ctx context.Context, tracer trace.Tracer) context.Context, method string, req proto.Message, fake *fakeClientStream, opts ...Option) streamResult {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx := context.Background()
+	if setup != nil {
+		ctx = setup(ctx, tp.Tracer("test"))
+	}
+	ctx, sp := tp.Tracer("test").Start(ctx, "rpc")
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		fake.ctx = ctx
+		return fake, nil
+	}
+
+	interceptor := New(append([]Option{WithTracerProvider(tp)}, opts...)...).StreamInterceptor()
+	stream, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, method, streamer)
+	if err != nil {
+		t.Fatalf("interceptor returned error: %v", err)
+	}
+	if err := stream.SendMsg(req); err != nil {
+		t.Fatalf("SendMsg returned error: %v", err)
+	}
+	var result streamResult
+	for {
+		err := stream.RecvMsg(&spanner.PartialResultSet{})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.err = err
+			break
+		}
+	}
+	sp.End()
+
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().SpanID() == sp.SpanContext().SpanID() {
+			result.rpc = span
+			continue
+		}
+		result.spans = append(result.spans, span)
+	}
+	return result
+}
+
+// unaryResult is the result of runUnary.
+type unaryResult struct {
+	spans []sdktrace.ReadOnlySpan
+	rpc   sdktrace.ReadOnlySpan
+	err   error
+}
+
+// runUnary invokes a unary RPC intercepted with opts, which replies reply with header and trailer, or returns invokeErr.
+func runUnary(t testing.TB, method string, req, reply proto.Message, header, trailer metadata.MD, invokeErr error, opts ...Option) unaryResult {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+	invoker := func(ctx context.Context, method string, req, out interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, opt := range opts {
+			switch opt := opt.(type) {
+			case grpc.HeaderCallOption:
+				*opt.HeaderAddr = header
+			case grpc.TrailerCallOption:
+				*opt.TrailerAddr = trailer
+			}
+		}
+		if invokeErr != nil {
+			return invokeErr
+		}
+		proto.Merge(out.(proto.Message), reply)
+		return nil
+	}
+
+	interceptor := New(append([]Option{WithTracerProvider(tp)}, opts...)...).UnaryInterceptor()
+	var result unaryResult
+	result.err = interceptor(ctx, method, req, reply.ProtoReflect().New().Interface(), nil, invoker)
+	sp.End()
+
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().SpanID() == sp.SpanContext().SpanID() {
+			result.rpc = span
+			continue
+		}
+		result.spans = append(result.spans, span)
+	}
+	return result
+}
+
+func mustStats(t testing.TB, s string) *spanner.ResultSetStats {
+	t.Helper()
+	var stats spanner.ResultSetStats
+	if err := protojson.Unmarshal([]byte(s), &stats); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+	return &stats
+}
+
+func attributeValue(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+// queryStatsWithPlan is stats of a PROFILE query whose plan is a distributed union over a table scan.
+const queryStatsWithPlan = `{
+  "queryPlan": {"planNodes": [
+    {"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union", "childLinks": [{"childIndex": 1}],
+     "executionStats": {"execution_summary": {"execution_start_timestamp": "1600000000.000000", "execution_end_timestamp": "1600000000.002000"}}},
+    {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+     "executionStats": {"execution_summary": {"execution_start_timestamp": "1600000000.000500", "execution_end_timestamp": "1600000000.001500"}}}
+  ]},
+  "queryStats": {"query_text": "SELECT * FROM Singers", "elapsed_time": "2 msecs", "rows_returned": "3"}
+}`
+
+func TestStreamInterceptorQueryWithPlan(t *testing.T) {
+	stats := mustStats(t, queryStatsWithPlan)
+	result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+		responses: []proto.Message{
+			&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}},
+			&spanner.PartialResultSet{Stats: stats},
+		},
+	}, WithDefaultDecorators())
+
+	if result.err != nil {
+		t.Fatalf("stream returned error: %v", result.err)
+	}
+	if v, _ := attributeValue(result.rpc.Attributes(), "query_text"); v.AsString() != "SELECT * FROM Singers" {
+		t.Errorf("query_text = %q, want %q", v.AsString(), "SELECT * FROM Singers")
+	}
+	if v, _ := attributeValue(result.rpc.Attributes(), "elapsed_time"); v.AsString() != "2 msecs" {
+		t.Errorf("elapsed_time = %q, want %q", v.AsString(), "2 msecs")
+	}
+
+	wantNames := []string{"1: Table Scan (Table: Singers)", "0: Distributed Union"}
+	if got := spanNames(result.spans); !equalStrings(got, wantNames) {
+		t.Fatalf("plan spans = %q, want %q", got, wantNames)
+	}
+	union, scan := result.spans[1], result.spans[0]
+	if union.Parent().SpanID() != result.rpc.SpanContext().SpanID() {
+		t.Errorf("%q is not a child of the RPC span", union.Name())
+	}
+	if scan.Parent().SpanID() != union.SpanContext().SpanID() {
+		t.Errorf("%q is not a child of %q", scan.Name(), union.Name())
+	}
+	if got, want := scan.EndTime().Sub(scan.StartTime()).Microseconds(), int64(1000); got != want {
+		t.Errorf("%q duration = %vus, want %vus", scan.Name(), got, want)
+	}
+}
+
+func TestStreamInterceptorDMLWithRowCount(t *testing.T) {
+	result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{
+		Sql: "UPDATE Singers SET FirstName = 'a' WHERE TRUE",
+		Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{
+			Begin: &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadWrite_{ReadWrite: &spanner.TransactionOptions_ReadWrite{}}},
+		}},
+	}, &fakeClientStream{
+		responses: []proto.Message{
+			&spanner.PartialResultSet{
+				Metadata: &spanner.ResultSetMetadata{Transaction: &spanner.Transaction{Id: []byte("txn")}},
+				Stats:    &spanner.ResultSetStats{RowCount: &spanner.ResultSetStats_RowCountExact{RowCountExact: 3}},
+			},
+		},
+	}, WithOperation(), WithPartialResultSetCount())
+
+	if result.err != nil {
+		t.Fatalf("stream returned error: %v", result.err)
+	}
+	if v, _ := attributeValue(result.rpc.Attributes(), "spanner.operation"); v.AsString() != "dml.read_write" {
+		t.Errorf("spanner.operation = %q, want %q", v.AsString(), "dml.read_write")
+	}
+	if v, _ := attributeValue(result.rpc.Attributes(), "spanner.partial_result_sets"); v.AsInt64() != 1 {
+		t.Errorf("spanner.partial_result_sets = %v, want 1", v.AsInt64())
+	}
+	if len(result.spans) != 0 {
+		t.Errorf("plan spans = %q, want none", spanNames(result.spans))
+	}
+}
+
+func TestStreamInterceptorError(t *testing.T) {
+	streamErr := status.Error(grpccodes.Aborted, "transaction aborted")
+	result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}}},
+		err:       streamErr,
+	}, WithErrorStatus())
+
+	if result.err != streamErr {
+		t.Fatalf("stream returned %v, want %v", result.err, streamErr)
+	}
+	if result.rpc.Status().Code != codes.Error {
+		t.Errorf("status = %v, want %v", result.rpc.Status().Code, codes.Error)
+	}
+	if v, _ := attributeValue(result.rpc.Attributes(), "rpc.grpc.status_code"); v.AsInt64() != int64(grpccodes.Aborted) {
+		t.Errorf("rpc.grpc.status_code = %v, want %v", v.AsInt64(), int64(grpccodes.Aborted))
+	}
+	if len(result.rpc.Events()) != 1 || result.rpc.Events()[0].Name != "exception" {
+		t.Errorf("events = %v, want an exception event", result.rpc.Events())
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func BenchmarkDecorateStats(b *testing.B) {
+	stats := mustStats(b, queryStatsWithPlan)
+	for _, bb := range []struct {
+		name    string
+		sampler sdktrace.Sampler
+	}{
+		{"NonRecording", sdktrace.NeverSample()},
+		{"Recording", sdktrace.AlwaysSample()},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(bb.sampler))
+			ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+			defer sp.End()
+			i := New(WithDefaultDecorators(), WithTracerProvider(tp))
+			ctx = contextWithMethod(ctx, executeStreamingSQLMethod)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				i.option.decorateStats(ctx, sp, stats)
+			}
+		})
+	}
+}
+
+func TestWithQueryFilter(t *testing.T) {
+	for _, tt := range []struct {
+		desc          string
+		opts          []Option
+		wantPlanSpans int
+		wantQueryText bool
+	}{
+		{"allowed", []Option{WithQueryFilter(func(sql string) bool { return sql == "SELECT * FROM Singers" })}, 2, true},
+		{"filtered", []Option{WithQueryFilter(func(sql string) bool { return false })}, 0, true},
+		{"filtered with stats decorators", []Option{WithQueryFilter(func(sql string) bool { return false }), WithQueryFilterForStatsDecorators()}, 0, false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+				responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+			}, append([]Option{WithDefaultDecorators()}, tt.opts...)...)
+
+			if len(result.spans) != tt.wantPlanSpans {
+				t.Errorf("plan spans = %q, want %d spans", spanNames(result.spans), tt.wantPlanSpans)
+			}
+			if _, ok := attributeValue(result.rpc.Attributes(), "query_text"); ok != tt.wantQueryText {
+				t.Errorf("query_text is set: %v, want %v", ok, tt.wantQueryText)
+			}
+		})
+	}
+}
+
+type querySpanKey struct{}
+
+func TestWithParentSpanFromContextKey(t *testing.T) {
+	var query trace.Span
+	result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+		ctx, query = tracer.Start(ctx, "query")
+		ctx = context.WithValue(ctx, querySpanKey{}, query)
+		ctx, _ = tracer.Start(ctx, "wrapper")
+		return ctx
+	}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+	}, WithDefaultDecorators(), WithParentSpanFromContextKey(querySpanKey{}))
+
+	if len(result.spans) != 2 {
+		t.Fatalf("plan spans = %q, want 2 spans", spanNames(result.spans))
+	}
+	if root := result.spans[1]; root.Parent().SpanID() != query.SpanContext().SpanID() {
+		t.Errorf("%q is not a child of the span under the key", root.Name())
+	}
+	if _, ok := attributeValue(result.rpc.Attributes(), "query_text"); !ok {
+		t.Errorf("query_text is not set on the innermost span")
+	}
+}
+
+func TestServerTimingOfCommit(t *testing.T) {
+	header := metadata.Pairs("server-timing", "gfet4t7; dur=12")
+	for _, tt := range []struct {
+		method  string
+		req     proto.Message
+		reply   proto.Message
+		wantKey string
+		notKey  string
+	}{
+		{commitMethod, &spanner.CommitRequest{}, &spanner.CommitResponse{}, "commit.gfe-server-timing", "gfe-server-timing"},
+		{executeSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &spanner.ResultSet{}, "gfe-server-timing", "commit.gfe-server-timing"},
+	} {
+		result := runUnary(t, tt.method, tt.req, tt.reply, header, nil, nil, WithDefaultDecorators())
+		if result.err != nil {
+			t.Fatalf("%s returned error: %v", tt.method, result.err)
+		}
+		if v, _ := attributeValue(result.rpc.Attributes(), tt.wantKey); v.AsInt64() != 12 {
+			t.Errorf("%s: %s = %v, want 12", tt.method, tt.wantKey, v.AsInt64())
+		}
+		if _, ok := attributeValue(result.rpc.Attributes(), tt.notKey); ok {
+			t.Errorf("%s: %s is set", tt.method, tt.notKey)
+		}
+	}
+}
+
+func TestWithPlanSpanStartOptions(t *testing.T) {
+	result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+	}, WithPlanSpanStartOptions(
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("component", "plan")),
+		// The timestamps of execution stats take precedence.
+		trace.WithTimestamp(time.Unix(0, 0)),
+	))
+
+	if len(result.spans) != 2 {
+		t.Fatalf("plan spans = %q, want 2 spans", spanNames(result.spans))
+	}
+	for _, span := range result.spans {
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("%q kind = %v, want %v", span.Name(), span.SpanKind(), trace.SpanKindClient)
+		}
+		if v, _ := attributeValue(span.Attributes(), "component"); v.AsString() != "plan" {
+			t.Errorf("%q component = %q, want %q", span.Name(), v.AsString(), "plan")
+		}
+	}
+	if got, want := result.spans[0].StartTime(), time.Unix(1600000000, 500000); !got.Equal(want) {
+		t.Errorf("%q start = %v, want %v", result.spans[0].Name(), got, want)
+	}
+}
+
+func TestContextWithPlanTracing(t *testing.T) {
+	for _, tt := range []struct {
+		enabled       bool
+		wantPlanSpans int
+	}{
+		{false, 0},
+		// The context takes precedence over WithQueryFilter.
+		{true, 2},
+	} {
+		result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+			return ContextWithPlanTracing(ctx, tt.enabled)
+		}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+		}, WithDefaultDecorators(), WithQueryFilter(func(sql string) bool { return false }))
+
+		if len(result.spans) != tt.wantPlanSpans {
+			t.Errorf("enabled: %v, plan spans = %q, want %d spans", tt.enabled, spanNames(result.spans), tt.wantPlanSpans)
+		}
+	}
+}
+
+func TestWithClientNameAndVersion(t *testing.T) {
+	opts := []Option{WithClientName("billing"), WithClientVersion("1.2.3")}
+	stream := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{}, opts...)
+	unary := runUnary(t, commitMethod, &spanner.CommitRequest{}, &spanner.CommitResponse{}, nil, nil, nil, opts...)
+	for _, rpc := range []sdktrace.ReadOnlySpan{stream.rpc, unary.rpc} {
+		for key, want := range map[string]string{"spanner.client_name": "billing", "spanner.client_version": "1.2.3"} {
+			if v, _ := attributeValue(rpc.Attributes(), key); v.AsString() != want {
+				t.Errorf("%s = %q, want %q", key, v.AsString(), want)
+			}
+		}
+	}
+}
+
+func TestWithRouteToLeader(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		outgoing metadata.MD
+		header   metadata.MD
+		want     attribute.Value
+		wantOk   bool
+	}{
+		{"outgoing metadata", metadata.Pairs(routeToLeaderHeader, "true"), nil, attribute.BoolValue(true), true},
+		{"outgoing metadata takes precedence", metadata.Pairs(routeToLeaderHeader, "false"), metadata.Pairs(routeToLeaderHeader, "true"), attribute.BoolValue(false), true},
+		{"response header", nil, metadata.Pairs(routeToLeaderHeader, "true"), attribute.BoolValue(true), true},
+		{"not a boolean", metadata.Pairs(routeToLeaderHeader, "leader"), nil, attribute.Value{}, false},
+		{"absent", nil, nil, attribute.Value{}, false},
+	} {
+		result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+			return metadata.NewOutgoingContext(ctx, tt.outgoing)
+		}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{
+			header:    tt.header,
+			responses: []proto.Message{&spanner.PartialResultSet{}},
+		}, WithRouteToLeader())
+		if result.err != nil {
+			t.Fatalf("%s: stream returned error: %v", tt.desc, result.err)
+		}
+		if v, ok := attributeValue(result.rpc.Attributes(), "spanner.route_to_leader"); ok != tt.wantOk || v != tt.want {
+			t.Errorf("%s: spanner.route_to_leader = (%v, %v), want (%v, %v)", tt.desc, v.Emit(), ok, tt.want.Emit(), tt.wantOk)
+		}
+	}
+}
+
+func TestRequestIDAttributes(t *testing.T) {
+	result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, requestIDHeader, "1.5b1fa1b2c3d4e5f6.1.2.34.3")
+	}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{}},
+	}, WithHeaderSpanDecorators(requestIDSpanDecorator))
+	if result.err != nil {
+		t.Fatalf("stream returned error: %v", result.err)
+	}
+	attrs := result.rpc.Attributes()
+	for key, want := range map[string]attribute.Value{
+		"spanner.request_id":         attribute.StringValue("1.5b1fa1b2c3d4e5f6.1.2.34.3"),
+		"spanner.request_id.version": attribute.Int64Value(1),
+		"spanner.request_id.process": attribute.StringValue("5b1fa1b2c3d4e5f6"),
+		"spanner.request_id.client":  attribute.Int64Value(1),
+		"spanner.request_id.channel": attribute.Int64Value(2),
+		"spanner.request_id.request": attribute.Int64Value(34),
+		"spanner.request_id.attempt": attribute.Int64Value(3),
+	} {
+		if v, _ := attributeValue(attrs, key); v != want {
+			t.Errorf("%s = %v, want %v", key, v.Emit(), want.Emit())
+		}
+	}
+
+	for _, id := range []string{"opaque-id", "1.process.1.2.34", "1..1.2.34.3", "1.process.1.2.x.3"} {
+		if got := requestIDAttributes(id); got != nil {
+			t.Errorf("requestIDAttributes(%q) = %v, want nil", id, got)
+		}
+	}
+}
+
+func TestWithResumeDeduplication(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	interceptor := New(WithTracerProvider(tp), WithDefaultDecorators(), WithResumeDeduplication()).StreamInterceptor()
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{
+			ctx:       ctx,
+			responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+		}, nil
+	}
+
+	for _, tt := range []struct {
+		desc        string
+		seqno       int64
+		resumeToken []byte
+		want        bool
+	}{
+		{"first attempt", 1, nil, false},
+		{"resumed", 1, []byte("token"), true},
+		{"resumed with another seqno", 2, []byte("token"), false},
+	} {
+		ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+		stream, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, executeStreamingSQLMethod, streamer)
+		if err != nil {
+			t.Fatalf("interceptor returned error: %v", err)
+		}
+		if err := stream.SendMsg(&spanner.ExecuteSqlRequest{
+			Sql:         "SELECT * FROM Singers",
+			Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Id{Id: []byte("txn")}},
+			Seqno:       tt.seqno,
+			ResumeToken: tt.resumeToken,
+		}); err != nil {
+			t.Fatalf("SendMsg returned error: %v", err)
+		}
+		for stream.RecvMsg(&spanner.PartialResultSet{}) == nil {
+		}
+		sp.End()
+
+		ended := recorder.Ended()
+		rpc := ended[len(ended)-1]
+		if v, _ := attributeValue(rpc.Attributes(), "plan.deduplicated"); v.AsBool() != tt.want {
+			t.Errorf("%s: plan.deduplicated = %v, want %v", tt.desc, v.AsBool(), tt.want)
+		}
+		if _, ok := attributeValue(rpc.Attributes(), "query_text"); ok == tt.want {
+			t.Errorf("%s: query_text is set = %v, want %v", tt.desc, ok, !tt.want)
+		}
+	}
+}
+
+func TestWithPerTraceSpanBudget(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	// Each plan of queryStatsWithPlan has 2 nodes, so the second plan in a trace exceeds the budget.
+	i := New(WithPerTraceSpanBudget(3), WithTracerProvider(tp))
+
+	traceA, _ := tp.Tracer("test").Start(context.Background(), "trace A")
+	traceB, _ := tp.Tracer("test").Start(context.Background(), "trace B")
+	for _, tt := range []struct {
+		desc      string
+		ctx       context.Context
+		wantPlans int
+	}{
+		{"first query in trace A", traceA, 2},
+		{"second query in trace A", traceA, 0},
+		{"first query in trace B", traceB, 2},
+	} {
+		before := len(recorder.Ended())
+		ctx, sp := tp.Tracer("test").Start(tt.ctx, "rpc")
+		i.option.decorateStats(contextWithMethod(ctx, executeStreamingSQLMethod), sp, mustStats(t, queryStatsWithPlan))
+		sp.End()
+
+		ended := recorder.Ended()[before:]
+		if got := len(ended) - 1; got != tt.wantPlans {
+			t.Errorf("%s: %d plan spans are emitted, want %d", tt.desc, got, tt.wantPlans)
+		}
+		v, ok := attributeValue(ended[len(ended)-1].Attributes(), "plan.budget_exceeded")
+		if want := tt.wantPlans == 0; ok != want || v.AsBool() != want {
+			t.Errorf("%s: plan.budget_exceeded = (%v, %v), want %v", tt.desc, v.AsBool(), ok, want)
+		}
+	}
+}
+
+func TestWithMethodFilter(t *testing.T) {
+	filter := WithMethodFilter(func(method string) bool {
+		return method == executeStreamingSQLMethod || method == executeSQLMethod
+	})
+	for _, tt := range []struct {
+		method string
+		want   bool
+	}{
+		{executeStreamingSQLMethod, true},
+		{streamingReadMethod, false},
+	} {
+		fake := &fakeClientStream{responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}}}
+		streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			fake.ctx = ctx
+			return fake, nil
+		}
+		stream, err := New(filter).StreamInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, tt.method, streamer)
+		if err != nil {
+			t.Fatalf("%s: interceptor returned error: %v", tt.method, err)
+		}
+		// Filtered methods get the stream of the streamer as is.
+		if got := stream != grpc.ClientStream(fake); got != tt.want {
+			t.Errorf("%s: stream is wrapped = %v, want %v", tt.method, got, tt.want)
+		}
+
+		result := runStream(t, tt.method, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+		}, filter, WithDefaultDecorators())
+		if _, ok := attributeValue(result.rpc.Attributes(), "query_text"); ok != tt.want {
+			t.Errorf("%s: query_text is set = %v, want %v", tt.method, ok, tt.want)
+		}
+		if got := len(result.spans) > 0; got != tt.want {
+			t.Errorf("%s: plan spans are emitted = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		method string
+		want   bool
+	}{
+		{executeSQLMethod, true},
+		{"/google.spanner.v1.Spanner/Read", false},
+	} {
+		result := runUnary(t, tt.method, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &spanner.ResultSet{Stats: mustStats(t, queryStatsWithPlan)}, nil, nil, nil,
+			filter, WithDefaultDecorators())
+		if _, ok := attributeValue(result.rpc.Attributes(), "elapsed_time"); ok != tt.want {
+			t.Errorf("%s: elapsed_time is set = %v, want %v", tt.method, ok, tt.want)
+		}
+		if got := len(result.spans) > 0; got != tt.want {
+			t.Errorf("%s: plan spans are emitted = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestWithCreateSpan(t *testing.T) {
+	abortedErr := status.Error(grpccodes.Aborted, "transaction aborted")
+	streamerErr := status.Error(grpccodes.Unavailable, "connection refused")
+	for _, tt := range []struct {
+		desc        string
+		err         error
+		streamerErr error
+		// cancel cancels the context of the stream after the first response instead of receiving until the end.
+		cancel bool
+	}{
+		{"EOF", nil, nil, false},
+		{"RecvMsg error", abortedErr, nil, false},
+		{"context cancelled without EOF", nil, nil, true},
+		{"streamer error", nil, streamerErr, false},
+	} {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx, sp := tp.Tracer("test").Start(ctx, "caller")
+		fake := &fakeClientStream{
+			responses: []proto.Message{
+				&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}},
+				&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)},
+			},
+			err: tt.err,
+		}
+		streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			fake.ctx = ctx
+			if tt.streamerErr != nil {
+				return nil, tt.streamerErr
+			}
+			return fake, nil
+		}
+
+		interceptor := New(WithTracerProvider(tp), WithDefaultDecorators(), WithCreateSpan(), WithErrorStatus()).StreamInterceptor()
+		stream, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, executeStreamingSQLMethod, streamer)
+		if err != tt.streamerErr {
+			t.Fatalf("%s: interceptor returned %v, want %v", tt.desc, err, tt.streamerErr)
+		}
+		wantErr := tt.streamerErr
+		if err == nil {
+			if err := stream.SendMsg(&spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}); err != nil {
+				t.Fatalf("%s: SendMsg returned error: %v", tt.desc, err)
+			}
+			if tt.cancel {
+				if err := stream.RecvMsg(&spanner.PartialResultSet{}); err != nil {
+					t.Fatalf("%s: RecvMsg returned error: %v", tt.desc, err)
+				}
+				cancel()
+			} else {
+				for stream.RecvMsg(&spanner.PartialResultSet{}) == nil {
+				}
+			}
+			wantErr = tt.err
+		}
+
+		// The span may be ended asynchronously after cancellation.
+		var created sdktrace.ReadOnlySpan
+		for deadline := time.Now().Add(time.Second); created == nil && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			for _, span := range recorder.Ended() {
+				if span.Name() == "google.spanner.v1.Spanner/ExecuteStreamingSql" {
+					created = span
+				}
+			}
+		}
+		sp.End()
+		cancel()
+		if created == nil {
+			t.Errorf("%s: the created span is not ended", tt.desc)
+			continue
+		}
+		if created.Parent().SpanID() != sp.SpanContext().SpanID() {
+			t.Errorf("%s: the created span is not a child of the span in the context", tt.desc)
+		}
+		if created.SpanKind() != trace.SpanKindClient {
+			t.Errorf("%s: span kind = %v, want %v", tt.desc, created.SpanKind(), trace.SpanKindClient)
+		}
+		if wantErr != nil {
+			if created.Status().Code != codes.Error {
+				t.Errorf("%s: status = %v, want %v", tt.desc, created.Status().Code, codes.Error)
+			}
+		} else if created.Status().Code == codes.Error {
+			t.Errorf("%s: status is %v", tt.desc, created.Status().Code)
+		}
+		// Only the created span is decorated, if the stats are received.
+		_, ok := attributeValue(created.Attributes(), "query_text")
+		if want := tt.streamerErr == nil && !tt.cancel; ok != want {
+			t.Errorf("%s: query_text is set = %v, want %v", tt.desc, ok, want)
+		}
+		ended := recorder.Ended()
+		if caller := ended[len(ended)-1]; len(caller.Attributes()) != 0 {
+			t.Errorf("%s: the span in the context is decorated: %v", tt.desc, caller.Attributes())
+		}
+	}
+}
+
+func TestWithReceivedMessageStats(t *testing.T) {
+	metadataMessage := &spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}, Values: []*structpb.Value{structpb.NewStringValue("a")}}
+	statsMessage := &spanner.PartialResultSet{Stats: mustStats(t, `{"queryStats": {"rows_returned": "1"}}`)}
+	wantBytes := int64(proto.Size(metadataMessage) + proto.Size(statsMessage))
+	for _, tt := range []struct {
+		desc string
+		opts []Option
+		// wantBytesReturned is true if spanner.bytes_returned is set.
+		wantBytesReturned bool
+	}{
+		{"alone", []Option{WithReceivedMessageStats()}, false},
+		{"with WithPartialResultSetCount", []Option{WithPartialResultSetCount(), WithReceivedMessageStats()}, false},
+		{"with WithBytesReturned", []Option{WithReceivedMessageStats(), WithBytesReturned()}, true},
+	} {
+		result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 'a'"}, &fakeClientStream{
+			responses: []proto.Message{metadataMessage, statsMessage},
+		}, tt.opts...)
+		if result.err != nil {
+			t.Fatalf("%s: stream returned error: %v", tt.desc, result.err)
+		}
+		attrs := result.rpc.Attributes()
+		if v, _ := attributeValue(attrs, "spanner.partial_result_sets"); v.AsInt64() != 2 {
+			t.Errorf("%s: spanner.partial_result_sets = %v, want 2", tt.desc, v.AsInt64())
+		}
+		if v, _ := attributeValue(attrs, "spanner.bytes_received"); v.AsInt64() != wantBytes {
+			t.Errorf("%s: spanner.bytes_received = %v, want %v", tt.desc, v.AsInt64(), wantBytes)
+		}
+		// Without bytes_returned in query stats, spanner.bytes_returned is the same as spanner.bytes_received.
+		v, ok := attributeValue(attrs, "spanner.bytes_returned")
+		if ok != tt.wantBytesReturned || tt.wantBytesReturned && v.AsInt64() != wantBytes {
+			t.Errorf("%s: spanner.bytes_returned = (%v, %v), want (%v, %v)", tt.desc, v.AsInt64(), ok, wantBytes, tt.wantBytesReturned)
+		}
+	}
+}
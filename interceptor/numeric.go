@@ -0,0 +1,73 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/apstndb/spannerotel/internal/plantotrace"
+	"github.com/apstndb/spannerotel/internal/statkey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// AttributeKeyStyle is the style of joining stat names and units in attribute keys of numeric stats.
+type AttributeKeyStyle = statkey.Style
+
+const (
+	// AttributeKeyStyleUnderscore joins names and units with "_", e.g. elapsed_time_ms. It is the default.
+	AttributeKeyStyleUnderscore = statkey.Underscore
+	// AttributeKeyStyleDot joins names and units with ".", e.g. elapsed_time.ms.
+	AttributeKeyStyleDot = statkey.Dot
+)
+
+// WithAttributeKeyStyle sets the style of attribute keys of numeric stats with units,
+// e.g. elapsed_time_ms, wait_time_ms and spanner.client_overhead_ms.
+func WithAttributeKeyStyle(style AttributeKeyStyle) Option {
+	return func(o *interceptorOption) {
+		o.keyStyle = style
+		o.planOptions = append(o.planOptions, plantotrace.WithKeyStyle(style))
+	}
+}
+
+// WithNumericQueryStats sets numeric query stats as attributes with units in their keys:
+// elapsed_time_ms, cpu_time_ms, memory_peak_usage_bytes and filesystem_delay_s.
+func WithNumericQueryStats() Option {
+	return func(o *interceptorOption) {
+		o.statsSpanDecorators = append(o.statsSpanDecorators, o.numericQueryStatsSpanDecorator)
+	}
+}
+
+func (o *interceptorOption) numericQueryStatsSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	if ms, ok := parseStatMillis(queryStatsField(stats, "elapsed_time").GetStringValue()); ok {
+		span.SetAttributes(statkey.ElapsedTime.Format(o.keyStyle).Float64(ms))
+	}
+	if ms, ok := parseStatMillis(queryStatsField(stats, "cpu_time").GetStringValue()); ok {
+		span.SetAttributes(statkey.CPUTime.Format(o.keyStyle).Float64(ms))
+	}
+	if n, ok := parseStatInt(queryStatsField(stats, "memory_peak_usage_bytes")); ok {
+		span.SetAttributes(statkey.MemoryPeakUsage.Format(o.keyStyle).Int64(n))
+	}
+	if ms, ok := parseStatMillis(queryStatsField(stats, "filesystem_delay_seconds").GetStringValue()); ok {
+		span.SetAttributes(statkey.FilesystemDelay.Format(o.keyStyle).Float64(ms / 1000))
+	}
+}
+
+// WithLockContention sets spanner.locking_delay_ms (in the configured key style) and spanner.lock_contention
+// from locking_delay in query stats, which is the time spent waiting for locks.
+// spanner.lock_contention is true if the locking delay is positive. Nothing is set if locking_delay is absent.
+func WithLockContention() Option {
+	return func(o *interceptorOption) {
+		o.statsSpanDecorators = append(o.statsSpanDecorators, o.lockContentionSpanDecorator)
+	}
+}
+
+func (o *interceptorOption) lockContentionSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	ms, ok := parseStatMillis(queryStatsField(stats, "locking_delay").GetStringValue())
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		statkey.LockingDelay.Format(o.keyStyle).Float64(ms),
+		attribute.Bool("spanner.lock_contention", ms > 0),
+	)
+}
@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"context"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithOpenCensusBridge attaches plan spans under the innermost OpenCensus span in the context,
+// e.g. spans which the Spanner client emits by OpenCensus, if it is newer than the OpenTelemetry span in the context.
+//
+// When octrace.DefaultTracer is the OpenTelemetry bridge (go.opentelemetry.io/otel/bridge/opencensus),
+// OpenCensus spans are also stored as OpenTelemetry spans in the context, so they are resolved without this option.
+// Otherwise, the OpenCensus span is only in the OpenCensus context, and plan spans would form a tree parallel to it.
+// In that case, its span context is used as a remote parent of plan spans. Stats decorators still decorate
+// the OpenTelemetry span in the context because the OpenCensus span can't be decorated through OpenTelemetry.
+func WithOpenCensusBridge() Option {
+	return func(o *interceptorOption) {
+		o.openCensusBridge = true
+	}
+}
+
+// openCensusParentSpan returns a non-recording span of the OpenCensus span in ctx
+// if it differs from the OpenTelemetry span in ctx.
+func openCensusParentSpan(ctx context.Context) (trace.Span, bool) {
+	ocSpan := octrace.FromContext(ctx)
+	if ocSpan == nil {
+		return nil, false
+	}
+	ocSpanContext := ocSpan.SpanContext()
+	var traceFlags trace.TraceFlags
+	if ocSpanContext.IsSampled() {
+		traceFlags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID(ocSpanContext.TraceID),
+		SpanID:     trace.SpanID(ocSpanContext.SpanID),
+		TraceFlags: traceFlags,
+	})
+	if !sc.IsValid() || sc.SpanID() == trace.SpanContextFromContext(ctx).SpanID() {
+		return nil, false
+	}
+	return trace.SpanFromContext(trace.ContextWithRemoteSpanContext(ctx, sc)), true
+}
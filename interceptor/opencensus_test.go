@@ -0,0 +1,44 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithOpenCensusBridge(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		var ocSpan *octrace.Span
+		var opts []Option
+		if enabled {
+			opts = append(opts, WithOpenCensusBridge())
+		}
+		result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+			// The Spanner client starts an OpenCensus span, which is not an OpenTelemetry span without the bridge.
+			ctx, ocSpan = octrace.StartSpan(ctx, "cloud.google.com/go/spanner.RowIterator", octrace.WithSampler(octrace.AlwaysSample()))
+			return ctx
+		}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers"}, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}},
+		}, opts...)
+		ocSpan.End()
+
+		if len(result.spans) != 2 {
+			t.Fatalf("bridge: %v, plan spans = %q, want 2 spans", enabled, spanNames(result.spans))
+		}
+		root := result.spans[1]
+		wantParent := result.rpc.SpanContext().SpanID()
+		if enabled {
+			wantParent = trace.SpanID(ocSpan.SpanContext().SpanID)
+			if got, want := root.SpanContext().TraceID(), trace.TraceID(ocSpan.SpanContext().TraceID); got != want {
+				t.Errorf("bridge: %v, trace ID = %v, want the OpenCensus trace ID %v", enabled, got, want)
+			}
+		}
+		if got := root.Parent().SpanID(); got != wantParent {
+			t.Errorf("bridge: %v, parent of %q = %v, want %v", enabled, root.Name(), got, wantParent)
+		}
+	}
+}
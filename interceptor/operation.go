@@ -0,0 +1,104 @@
+package interceptor
+
+import (
+	"strings"
+
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// operationKind returns the operation class of the method, e.g. query, dml and read.
+// isDML is true if the result is known to be of DML, e.g. stats contain row counts.
+func operationKind(method string, isDML bool) string {
+	switch method[strings.LastIndex(method, "/")+1:] {
+	case "ExecuteSql", "ExecuteStreamingSql":
+		if isDML {
+			return "dml"
+		}
+		return "query"
+	case "ExecuteBatchDml":
+		return "dml"
+	case "Read", "StreamingRead":
+		return "read"
+	case "Commit":
+		return "commit"
+	case "Rollback":
+		return "rollback"
+	case "BeginTransaction":
+		return "begin_transaction"
+	case "PartitionQuery", "PartitionRead":
+		return "partition"
+	default:
+		return ""
+	}
+}
+
+func optionsTransactionType(options *spanner.TransactionOptions) string {
+	switch {
+	case options.GetReadOnly() != nil:
+		return "read_only"
+	case options.GetReadWrite() != nil:
+		return "read_write"
+	case options.GetPartitionedDml() != nil:
+		return "pdml"
+	default:
+		return ""
+	}
+}
+
+// selectorTransactionType returns the transaction type from the transaction selector of a request.
+// It is empty for an existing transaction id because its type is not in the request.
+func selectorTransactionType(selector *spanner.TransactionSelector) string {
+	switch {
+	case selector.GetSingleUse() != nil:
+		return "single_use"
+	case selector.GetBegin() != nil:
+		return optionsTransactionType(selector.GetBegin())
+	default:
+		return ""
+	}
+}
+
+// metadataTransactionType infers the transaction type from a transaction returned in ResultSetMetadata,
+// which is only returned when the transaction is begun by the request.
+// Read-only transactions have a read timestamp and read-write transactions don't.
+func metadataTransactionType(metadata *spanner.ResultSetMetadata) string {
+	transaction := metadata.GetTransaction()
+	switch {
+	case transaction == nil:
+		return ""
+	case transaction.GetReadTimestamp() != nil:
+		return "read_only"
+	case len(transaction.GetId()) > 0:
+		return "read_write"
+	default:
+		return ""
+	}
+}
+
+// operationName joins the operation kind and the transaction type like query.read_only.
+// The transaction type is omitted if it is unknown.
+func operationName(kind, transactionType string) string {
+	if kind == "" || transactionType == "" {
+		return kind
+	}
+	return kind + "." + transactionType
+}
+
+// requestTransactionType returns the transaction type of a request if it is determinable.
+func requestTransactionType(req interface{}) string {
+	switch req := req.(type) {
+	case interface {
+		GetTransaction() *spanner.TransactionSelector
+	}:
+		return selectorTransactionType(req.GetTransaction())
+	case *spanner.BeginTransactionRequest:
+		return optionsTransactionType(req.GetOptions())
+	case *spanner.CommitRequest:
+		if req.GetSingleUseTransaction() != nil {
+			return "single_use"
+		}
+		return "read_write"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,62 @@
+package interceptor
+
+import (
+	"testing"
+
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestOperationName(t *testing.T) {
+	readOnly := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadOnly_{ReadOnly: &spanner.TransactionOptions_ReadOnly{}}}
+	readWrite := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadWrite_{ReadWrite: &spanner.TransactionOptions_ReadWrite{}}}
+	pdml := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_PartitionedDml_{PartitionedDml: &spanner.TransactionOptions_PartitionedDml{}}}
+	for _, tt := range []struct {
+		desc   string
+		method string
+		isDML  bool
+		req    interface{}
+		want   string
+	}{
+		{"single-use query", executeStreamingSQLMethod, false,
+			&spanner.ExecuteSqlRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_SingleUse{SingleUse: readOnly}}},
+			"query.single_use"},
+		{"query beginning a read-only transaction", executeStreamingSQLMethod, false,
+			&spanner.ExecuteSqlRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{Begin: readOnly}}},
+			"query.read_only"},
+		{"DML beginning a read-write transaction", executeSQLMethod, true,
+			&spanner.ExecuteSqlRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{Begin: readWrite}}},
+			"dml.read_write"},
+		{"partitioned DML", executeSQLMethod, true,
+			&spanner.ExecuteSqlRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{Begin: pdml}}},
+			"dml.pdml"},
+		{"read in an existing transaction", "/google.spanner.v1.Spanner/StreamingRead", false,
+			&spanner.ReadRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Id{Id: []byte("txn")}}},
+			"read"},
+		{"query without a selector", executeStreamingSQLMethod, false, &spanner.ExecuteSqlRequest{}, "query"},
+		{"single-use commit", commitMethod, false,
+			&spanner.CommitRequest{Transaction: &spanner.CommitRequest_SingleUseTransaction{SingleUseTransaction: readWrite}},
+			"commit.single_use"},
+		{"unknown method", "/google.spanner.v1.Spanner/CreateSession", false, &spanner.CreateSessionRequest{}, ""},
+	} {
+		if got := operationName(operationKind(tt.method, tt.isDML), requestTransactionType(tt.req)); got != tt.want {
+			t.Errorf("%s: operation = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataTransactionType(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		metadata *spanner.ResultSetMetadata
+		want     string
+	}{
+		{"no transaction", &spanner.ResultSetMetadata{}, ""},
+		{"read-only", &spanner.ResultSetMetadata{Transaction: &spanner.Transaction{Id: []byte("txn"), ReadTimestamp: timestamppb.Now()}}, "read_only"},
+		{"read-write", &spanner.ResultSetMetadata{Transaction: &spanner.Transaction{Id: []byte("txn")}}, "read_write"},
+	} {
+		if got := metadataTransactionType(tt.metadata); got != tt.want {
+			t.Errorf("%s: metadataTransactionType() = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package interceptor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apstndb/spannerotel/internal/plantotrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// planHashes maps query fingerprints to the last observed plan hashes and holds at most max fingerprints.
+// When it is full, the oldest inserted fingerprint is evicted. It is safe for concurrent use.
+type planHashes struct {
+	mu     sync.Mutex
+	max    int
+	hashes map[string]string
+	order  []string
+}
+
+func newPlanHashes(max int) *planHashes {
+	return &planHashes{
+		max:    max,
+		hashes: make(map[string]string),
+	}
+}
+
+// swap records hash for fingerprint and returns the previous hash, or "" if fingerprint is not known.
+func (p *planHashes) swap(fingerprint, hash string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev, ok := p.hashes[fingerprint]
+	if !ok {
+		if len(p.order) >= p.max {
+			delete(p.hashes, p.order[0])
+			p.order = p.order[1:]
+		}
+		p.order = append(p.order, fingerprint)
+	}
+	p.hashes[fingerprint] = hash
+	return prev
+}
+
+// WithPlanChangeDetection sets spanner.plan_hash, and spanner.plan_changed if the plan hash differs from
+// the one previously observed for the same query fingerprint (see WithFirstPlanPerFingerprint), on the RPC span and the root plan span.
+// spanner.plan_changed is false for unchanged plans and is not set for the first observation.
+// Plan hashes of at most 1024 fingerprints are remembered; the oldest one is evicted when full,
+// so a change right after eviction is not detected. It is safe to share an interceptor with this option between goroutines.
+func WithPlanChangeDetection() Option {
+	return func(o *interceptorOption) {
+		hashes := newPlanHashes(defaultMaxTrackedFingerprints)
+		o.statsSpanDecorators = append(o.statsSpanDecorators, func(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+			planChangeSpanDecorator(hashes, span, stats)
+		})
+		o.planRootAttributeKeys = append(o.planRootAttributeKeys, "spanner.plan_hash", "spanner.plan_changed")
+	}
+}
+
+func planChangeSpanDecorator(hashes *planHashes, span trace.Span, stats *spanner.ResultSetStats) {
+	planNodes := stats.GetQueryPlan().GetPlanNodes()
+	fingerprint := queryFingerprint(stats)
+	if len(planNodes) == 0 || fingerprint == "" {
+		return
+	}
+	hash := plantotrace.PlanHash(planNodes)
+	span.SetAttributes(attribute.String("spanner.plan_hash", hash))
+	if prev := hashes.swap(fingerprint, hash); prev != "" {
+		span.SetAttributes(attribute.Bool("spanner.plan_changed", prev != hash))
+	}
+}
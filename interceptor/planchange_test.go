@@ -0,0 +1,49 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithPlanChangeDetection(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	i := New(WithPlanChangeDetection(), WithTracerProvider(tp))
+
+	indexScanStats := strings.Replace(queryStatsWithPlan, `"scan_type": "TableScan", "scan_target": "Singers"`, `"scan_type": "IndexScan", "scan_target": "SingersByName"`, 1)
+	for n, tt := range []struct {
+		stats           string
+		wantPlanChanged *bool
+	}{
+		{queryStatsWithPlan, nil},
+		{queryStatsWithPlan, boolPtr(false)},
+		{indexScanStats, boolPtr(true)},
+	} {
+		ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+		i.option.decorateStats(contextWithMethod(ctx, executeStreamingSQLMethod), sp, mustStats(t, tt.stats))
+		sp.End()
+
+		ended := recorder.Ended()
+		rpc, planRoot := ended[len(ended)-1], ended[len(ended)-2]
+		for _, span := range []sdktrace.ReadOnlySpan{rpc, planRoot} {
+			if _, ok := attributeValue(span.Attributes(), "spanner.plan_hash"); !ok {
+				t.Errorf("execution %d: spanner.plan_hash is not set on %q", n, span.Name())
+			}
+			v, ok := attributeValue(span.Attributes(), "spanner.plan_changed")
+			switch {
+			case tt.wantPlanChanged == nil && ok:
+				t.Errorf("execution %d: spanner.plan_changed = %v on %q, want unset", n, v.AsBool(), span.Name())
+			case tt.wantPlanChanged != nil && (!ok || v.AsBool() != *tt.wantPlanChanged):
+				t.Errorf("execution %d: spanner.plan_changed = %v (set: %v) on %q, want %v", n, v.AsBool(), ok, span.Name(), *tt.wantPlanChanged)
+			}
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
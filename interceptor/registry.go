@@ -0,0 +1,69 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// decoratorRegistry maps names of built-in decorators to options enabling them.
+var decoratorRegistry = map[string]Option{
+	"query_text":       WithStatsSpanDecorators(queryTextSpanDecorator),
+	"elapsed_time":     WithStatsSpanDecorators(elapsedTimeSpanDecorator),
+	"rows_returned":    WithStatsSpanDecorators(rowsReturnedSpanDecorator),
+	"stats_sampled":    WithStatsSpanDecorators(statsSampledSpanDecorator),
+	"scan_efficiency":  WithStatsSpanDecorators(scanEfficiencySpanDecorator),
+	"query_hints":      WithStatsSpanDecorators(queryHintsSpanDecorator),
+	"read_timestamp":   WithResultSetMetadataSpanDecorators(readTimestampSpanDecorator),
+	"gfe":              WithHeaderSpanDecorators(gfeServerTimingSpanDecorator),
+	"request_id":       WithHeaderSpanDecorators(requestIDSpanDecorator),
+	"served_region":    WithHeaderSpanDecorators(servedRegionSpanDecorator),
+	"route_to_leader":  WithRouteToLeader(),
+	"backend_version":  WithHeaderSpanDecorators(backendVersionSpanDecorator),
+	"cache_hit":        WithHeaderSpanDecorators(cacheHitSpanDecorator),
+	"param_types":      WithRequestSpanDecorators(paramTypesSpanDecorator),
+	"read_request":     WithRequestSpanDecorators(readRequestSpanDecorator),
+	"request_priority": WithRequestPriority(),
+	"transaction_info": WithTransactionInfo(),
+	"db_semconv":       WithDBSemanticConventions(),
+	"db_namespace":     WithDBNamespace(),
+	"session_name":     WithSessionName(),
+	"request_details":  WithRequestDetails(),
+}
+
+// DecoratorNames returns the sorted names of built-in decorators which can be passed to WithDecoratorsByName.
+func DecoratorNames() []string {
+	names := make([]string, 0, len(decoratorRegistry))
+	for name := range decoratorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithDecoratorsByName enables built-in decorators by their names, e.g. from a configuration file.
+// Unknown names are reported to the OpenTelemetry error handler with the available names, and ignored;
+// the other names are still enabled, so a typo doesn't disable all decorators.
+func WithDecoratorsByName(names ...string) Option {
+	return func(o *interceptorOption) {
+		for _, name := range names {
+			opt, ok := decoratorRegistry[name]
+			if !ok {
+				otel.Handle(fmt.Errorf("spannerotel: unknown decorator %q, available: %v", name, DecoratorNames()))
+				continue
+			}
+			opt(o)
+		}
+	}
+}
+
+func rowsReturnedSpanDecorator(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+	if n, ok := parseStatInt(queryStatsField(stats, "rows_returned")); ok {
+		span.SetAttributes(attribute.Int64("rows_returned", n))
+	}
+}
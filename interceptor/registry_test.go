@@ -0,0 +1,49 @@
+package interceptor
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecoratorNames(t *testing.T) {
+	names := DecoratorNames()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("DecoratorNames() = %q is not sorted", names)
+	}
+	if len(names) != len(decoratorRegistry) {
+		t.Errorf("DecoratorNames() returned %d names, want %d", len(names), len(decoratorRegistry))
+	}
+}
+
+func TestWithDecoratorsByName(t *testing.T) {
+	var errs []error
+	prev := otel.GetErrorHandler()
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { errs = append(errs, err) }))
+	result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, `{"queryStats": {"query_text": "SELECT 1", "rows_returned": "1"}}`)}},
+	}, WithDecoratorsByName("query_text", "no_such_decorator", "rows_returned"))
+	otel.SetErrorHandler(prev)
+
+	if result.err != nil {
+		t.Fatalf("stream returned error: %v", result.err)
+	}
+	// Known names are enabled regardless of the unknown one.
+	attrs := result.rpc.Attributes()
+	if v, _ := attributeValue(attrs, "query_text"); v.AsString() != "SELECT 1" {
+		t.Errorf("query_text = %q, want %q", v.AsString(), "SELECT 1")
+	}
+	if v, _ := attributeValue(attrs, "rows_returned"); v.AsInt64() != 1 {
+		t.Errorf("rows_returned = %v, want 1", v.AsInt64())
+	}
+	if _, ok := attributeValue(attrs, "elapsed_time"); ok {
+		t.Errorf("elapsed_time is set without being enabled")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"no_such_decorator"`) {
+		t.Errorf("errors = %v, want one error reporting the unknown name", errs)
+	}
+}
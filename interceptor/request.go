@@ -0,0 +1,145 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithParamTypes sets spanner.param_count and spanner.param_types, the Spanner types of query parameters
+// sorted by parameter name, from ExecuteSqlRequest. Parameter values are never recorded.
+// Parameters without an explicit type are recorded as UNSPECIFIED.
+func WithParamTypes() Option {
+	return WithRequestSpanDecorators(paramTypesSpanDecorator)
+}
+
+func paramTypesSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	r, ok := req.(*spanner.ExecuteSqlRequest)
+	if !ok {
+		return
+	}
+	names := make([]string, 0, len(r.GetParams().GetFields()))
+	for name := range r.GetParams().GetFields() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	types := make([]string, 0, len(names))
+	for _, name := range names {
+		types = append(types, typeString(r.GetParamTypes()[name]))
+	}
+	span.SetAttributes(
+		attribute.Int("spanner.param_count", len(names)),
+		attribute.StringSlice("spanner.param_types", types),
+	)
+}
+
+// typeString renders a Spanner type like INT64, ARRAY<STRING> or STRUCT<a INT64>.
+func typeString(typ *spanner.Type) string {
+	switch typ.GetCode() {
+	case spanner.TypeCode_TYPE_CODE_UNSPECIFIED:
+		return "UNSPECIFIED"
+	case spanner.TypeCode_ARRAY:
+		return "ARRAY<" + typeString(typ.GetArrayElementType()) + ">"
+	case spanner.TypeCode_STRUCT:
+		var fields []string
+		for _, field := range typ.GetStructType().GetFields() {
+			fields = append(fields, strings.TrimSpace(field.GetName()+" "+typeString(field.GetType())))
+		}
+		return "STRUCT<" + strings.Join(fields, ", ") + ">"
+	default:
+		return typ.GetCode().String()
+	}
+}
+
+func isReadMethod(method string) bool {
+	switch method {
+	case "/google.spanner.v1.Spanner/Read", "/google.spanner.v1.Spanner/StreamingRead":
+		return true
+	default:
+		return false
+	}
+}
+
+// readRequestSpanDecorator sets spanner.read_table, spanner.read_index and spanner.read_keyset from ReadRequest.
+// spanner.read_keyset is "all" or the numbers of keys and ranges like "keys=1,ranges=0".
+func readRequestSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	r, ok := req.(*spanner.ReadRequest)
+	if !ok {
+		return
+	}
+	attrs := []attribute.KeyValue{attribute.String("spanner.read_table", r.GetTable())}
+	if r.GetIndex() != "" {
+		attrs = append(attrs, attribute.String("spanner.read_index", r.GetIndex()))
+	}
+	if r.GetKeySet().GetAll() {
+		attrs = append(attrs, attribute.String("spanner.read_keyset", "all"))
+	} else {
+		attrs = append(attrs, attribute.String("spanner.read_keyset", fmt.Sprintf("keys=%d,ranges=%d", len(r.GetKeySet().GetKeys()), len(r.GetKeySet().GetRanges()))))
+	}
+	span.SetAttributes(attrs...)
+}
+
+// WithRequestDetails sets attributes from ExecuteSqlRequest when it is sent, without relying on query_text in stats,
+// which is only returned with query stats: spanner.sql, spanner.param_count, spanner.seqno and spanner.query_mode.
+// For ReadRequest, spanner.read_table, spanner.read_index, spanner.read_keyset and spanner.read_columns are set.
+// spanner.sql is recorded regardless of WithQueryFilter, so don't use this option if SQL may contain sensitive literals.
+func WithRequestDetails() Option {
+	return WithRequestSpanDecorators(requestDetailsSpanDecorator)
+}
+
+func requestDetailsSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	switch r := req.(type) {
+	case *spanner.ExecuteSqlRequest:
+		span.SetAttributes(
+			attribute.String("spanner.sql", r.GetSql()),
+			attribute.Int("spanner.param_count", len(r.GetParams().GetFields())),
+			attribute.Int64("spanner.seqno", r.GetSeqno()),
+			queryModeAttribute(r.GetQueryMode()),
+		)
+	case *spanner.ReadRequest:
+		readRequestSpanDecorator(ctx, span, req)
+		span.SetAttributes(attribute.Int("spanner.read_columns", len(r.GetColumns())))
+	}
+}
+
+// queryModeAttribute returns spanner.query_mode like NORMAL, PLAN and PROFILE.
+func queryModeAttribute(mode spanner.ExecuteSqlRequest_QueryMode) attribute.KeyValue {
+	return attribute.String("spanner.query_mode", mode.String())
+}
+
+// WithQueryMode sets spanner.query_mode, the QueryMode of ExecuteSqlRequest like NORMAL, PLAN and PROFILE,
+// on the RPC span and the root plan span, so plan-only analysis runs are distinguishable from profiled queries.
+// Plan spans of PLAN mode have no execution stats, so their timestamps are not meaningful.
+func WithQueryMode() Option {
+	return func(o *interceptorOption) {
+		o.queryMode = true
+		o.requestSpanDecorators = append(o.requestSpanDecorators, queryModeSpanDecorator)
+	}
+}
+
+func queryModeSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	if r, ok := req.(*spanner.ExecuteSqlRequest); ok {
+		span.SetAttributes(queryModeAttribute(r.GetQueryMode()))
+	}
+}
+
+type queryModeKey struct{}
+
+// contextWithQueryMode returns a context with the QueryMode of req if it is ExecuteSqlRequest.
+func contextWithQueryMode(ctx context.Context, req interface{}) context.Context {
+	if r, ok := req.(*spanner.ExecuteSqlRequest); ok {
+		return context.WithValue(ctx, queryModeKey{}, r.GetQueryMode())
+	}
+	return ctx
+}
+
+func queryModeFromContext(ctx context.Context) (spanner.ExecuteSqlRequest_QueryMode, bool) {
+	mode, ok := ctx.Value(queryModeKey{}).(spanner.ExecuteSqlRequest_QueryMode)
+	return mode, ok
+}
@@ -0,0 +1,147 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const streamingReadMethod = "/google.spanner.v1.Spanner/StreamingRead"
+
+func TestStreamingRead(t *testing.T) {
+	result := runStream(t, streamingReadMethod, &spanner.ReadRequest{
+		Table:   "Singers",
+		Index:   "SingersByName",
+		Columns: []string{"SingerId", "FirstName"},
+		KeySet:  &spanner.KeySet{Keys: []*structpb.ListValue{{Values: []*structpb.Value{structpb.NewStringValue("1")}}}},
+	}, &fakeClientStream{
+		responses: []proto.Message{&spanner.PartialResultSet{Stats: mustStats(t, `{"queryStats": {"elapsed_time": "1 msecs", "rows_returned": "1"}}`)}},
+	}, WithDefaultDecorators())
+
+	if result.err != nil {
+		t.Fatalf("stream returned error: %v", result.err)
+	}
+	attrs := result.rpc.Attributes()
+	if v, ok := attributeValue(attrs, "query_text"); ok {
+		t.Errorf("query_text = %q is set for a read", v.AsString())
+	}
+	for key, want := range map[string]string{
+		"spanner.read_table":  "Singers",
+		"spanner.read_index":  "SingersByName",
+		"spanner.read_keyset": "keys=1,ranges=0",
+		"elapsed_time":        "1 msecs",
+	} {
+		if v, _ := attributeValue(attrs, key); v.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, v.AsString(), want)
+		}
+	}
+}
+
+func TestWithRequestDetails(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		req    proto.Message
+		want   map[string]attribute.Value
+	}{
+		{executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{
+			Sql:       "SELECT * FROM Singers WHERE SingerId = @id AND FirstName = @name",
+			Params:    &structpb.Struct{Fields: map[string]*structpb.Value{"id": structpb.NewStringValue("1"), "name": structpb.NewStringValue("Marc")}},
+			Seqno:     3,
+			QueryMode: spanner.ExecuteSqlRequest_PROFILE,
+		}, map[string]attribute.Value{
+			"spanner.sql":         attribute.StringValue("SELECT * FROM Singers WHERE SingerId = @id AND FirstName = @name"),
+			"spanner.param_count": attribute.IntValue(2),
+			"spanner.seqno":       attribute.Int64Value(3),
+			"spanner.query_mode":  attribute.StringValue("PROFILE"),
+		}},
+		{streamingReadMethod, &spanner.ReadRequest{
+			Table:   "Singers",
+			Index:   "SingersByName",
+			Columns: []string{"SingerId", "FirstName"},
+			KeySet:  &spanner.KeySet{All: true},
+		}, map[string]attribute.Value{
+			"spanner.read_table":   attribute.StringValue("Singers"),
+			"spanner.read_index":   attribute.StringValue("SingersByName"),
+			"spanner.read_keyset":  attribute.StringValue("all"),
+			"spanner.read_columns": attribute.IntValue(2),
+		}},
+	} {
+		// No stats are returned, so the attributes come from the request.
+		result := runStream(t, tt.method, tt.req, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}}},
+		}, WithRequestDetails())
+		if result.err != nil {
+			t.Fatalf("%s: stream returned error: %v", tt.method, result.err)
+		}
+		attrs := result.rpc.Attributes()
+		for key, want := range tt.want {
+			if v, ok := attributeValue(attrs, key); !ok || v != want {
+				t.Errorf("%s: %s = (%v, %v), want %v", tt.method, key, v.Emit(), ok, want.Emit())
+			}
+		}
+		if len(attrs) != len(tt.want) {
+			t.Errorf("%s: attributes = %v, want %d attributes", tt.method, attrs, len(tt.want))
+		}
+	}
+}
+
+func TestWithQueryMode(t *testing.T) {
+	for _, tt := range []struct {
+		mode  spanner.ExecuteSqlRequest_QueryMode
+		stats string
+		// wantPlan is true if plan spans are emitted.
+		wantPlan bool
+	}{
+		// NORMAL queries return no plan.
+		{spanner.ExecuteSqlRequest_NORMAL, `{"queryStats": {"query_text": "SELECT * FROM Singers"}}`, false},
+		// PLAN queries return a plan without execution stats.
+		{spanner.ExecuteSqlRequest_PLAN, `{"queryPlan": {"planNodes": [
+		  {"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union", "childLinks": [{"childIndex": 1}]},
+		  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}}
+		]}}`, true},
+		{spanner.ExecuteSqlRequest_PROFILE, queryStatsWithPlan, true},
+	} {
+		req := &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers", QueryMode: tt.mode}
+		stream := runStream(t, executeStreamingSQLMethod, req, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}, Stats: mustStats(t, tt.stats)}},
+		}, WithQueryMode())
+		unary := runUnary(t, executeSQLMethod, req, &spanner.ResultSet{Metadata: &spanner.ResultSetMetadata{}, Stats: mustStats(t, tt.stats)}, nil, nil, nil, WithQueryMode())
+		for _, result := range []struct {
+			method string
+			spans  []sdktrace.ReadOnlySpan
+			rpc    sdktrace.ReadOnlySpan
+			err    error
+		}{
+			{executeStreamingSQLMethod, stream.spans, stream.rpc, stream.err},
+			{executeSQLMethod, unary.spans, unary.rpc, unary.err},
+		} {
+			if result.err != nil {
+				t.Fatalf("%v %s: returned error: %v", tt.mode, result.method, result.err)
+			}
+			if v, _ := attributeValue(result.rpc.Attributes(), "spanner.query_mode"); v.AsString() != tt.mode.String() {
+				t.Errorf("%v %s: spanner.query_mode = %q, want %q", tt.mode, result.method, v.AsString(), tt.mode.String())
+			}
+			if got := len(result.spans) > 0; got != tt.wantPlan {
+				t.Fatalf("%v %s: plan spans are emitted = %v, want %v", tt.mode, result.method, got, tt.wantPlan)
+			}
+			if !tt.wantPlan {
+				continue
+			}
+			// The root plan span, which ends last, also has the mode.
+			root := result.spans[len(result.spans)-1]
+			if v, _ := attributeValue(root.Attributes(), "spanner.query_mode"); v.AsString() != tt.mode.String() {
+				t.Errorf("%v %s: spanner.query_mode of %q = %q, want %q", tt.mode, result.method, root.Name(), v.AsString(), tt.mode.String())
+			}
+		}
+	}
+
+	// Modes are only taken from ExecuteSqlRequest.
+	if mode, ok := queryModeFromContext(contextWithQueryMode(context.Background(), &spanner.ReadRequest{Table: "Singers"})); ok {
+		t.Errorf("query mode of ReadRequest = %v, want none", mode)
+	}
+}
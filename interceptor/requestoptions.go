@@ -0,0 +1,226 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+type requestOptionsGetter interface {
+	GetRequestOptions() *spanner.RequestOptions
+}
+
+// WithRequestTags sets spanner.request_tag and spanner.transaction_tag from RequestOptions of requests,
+// e.g. ExecuteSqlRequest, ReadRequest and CommitRequest, if they are not empty.
+// They correlate traces with Spanner's statistics tables like SPANNER_SYS.QUERY_STATS_TOP_MINUTE, which are keyed by tags.
+func WithRequestTags() Option {
+	return WithRequestSpanDecorators(requestTagsSpanDecorator)
+}
+
+func requestTagsSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	r, ok := req.(requestOptionsGetter)
+	if !ok {
+		return
+	}
+	if tag := r.GetRequestOptions().GetRequestTag(); tag != "" {
+		span.SetAttributes(attribute.String("spanner.request_tag", tag))
+	}
+	if tag := r.GetRequestOptions().GetTransactionTag(); tag != "" {
+		span.SetAttributes(attribute.String("spanner.transaction_tag", tag))
+	}
+}
+
+// WithRequestPriority sets spanner.request_priority from RequestOptions.priority of requests, e.g. "HIGH", if it is specified,
+// so latency of HIGH, MEDIUM and LOW priority requests can be compared.
+func WithRequestPriority() Option {
+	return WithRequestSpanDecorators(requestPrioritySpanDecorator)
+}
+
+func requestPrioritySpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	r, ok := req.(requestOptionsGetter)
+	if !ok {
+		return
+	}
+	priority := r.GetRequestOptions().GetPriority()
+	if priority == spanner.RequestOptions_PRIORITY_UNSPECIFIED {
+		return
+	}
+	span.SetAttributes(attribute.String("spanner.request_priority", strings.TrimPrefix(priority.String(), "PRIORITY_")))
+}
+
+// maxRequestTagLen is the maximum length of request tags accepted by Spanner.
+const maxRequestTagLen = 50
+
+const autoRequestTagPrefix = "trace_id="
+
+// WithAutoRequestTag rewrites RequestOptions.request_tag of ExecuteSqlRequest, ExecuteBatchDmlRequest and ReadRequest
+// to include the trace ID of the span in the context, like "trace_id=4bf92f3577b34da6a3ce929d0e0e4736",
+// so server-side statistics (SPANNER_SYS tables) can be joined back to client traces.
+// An existing tag is kept and the trace ID is appended to it after a space.
+// The request is not rewritten if the span context is invalid, if the tag already has a trace ID,
+// e.g. when the request is sent again, or if the tag would exceed 50 characters, which is the limit of Spanner.
+// The trace ID takes 41 characters, so an existing tag is only kept if it has at most 8 characters;
+// longer tags are left as is without the trace ID.
+func WithAutoRequestTag() Option {
+	return func(o *interceptorOption) {
+		o.autoRequestTag = true
+	}
+}
+
+// WithBaggageRequestTags copies the members of keys in the baggage of the context into the tags of requests,
+// like "tenant=acme,feature=search", so server-side statistics can be attributed to the callers up to the frontend.
+// RequestOptions.request_tag of ExecuteSqlRequest, ExecuteBatchDmlRequest and ReadRequest is rewritten,
+// and so is RequestOptions.transaction_tag of requests which belong to a transaction, including BeginTransactionRequest and CommitRequest.
+// Spanner expects the same transaction tag for all requests of a transaction, so the baggage should not change within a transaction.
+// An existing tag is kept and the members are appended to it after a space.
+// A tag is not rewritten if it already has the members, if the members contain characters other than printable ASCII,
+// or if the tag would exceed 50 characters.
+func WithBaggageRequestTags(keys ...string) Option {
+	return func(o *interceptorOption) {
+		o.baggageTagKeys = append(o.baggageTagKeys, keys...)
+	}
+}
+
+// tagRequest rewrites the tags of req if WithBaggageRequestTags or WithAutoRequestTag is set.
+func (o *interceptorOption) tagRequest(ctx context.Context, req interface{}) {
+	if len(o.baggageTagKeys) > 0 {
+		tagRequestWithBaggage(ctx, req, o.baggageTagKeys)
+	}
+	if o.autoRequestTag {
+		tagRequestWithTraceID(ctx, req)
+	}
+}
+
+// requestOptionsField returns the pointer to the RequestOptions field of req,
+// and whether its request_tag is applicable.
+func requestOptionsField(req interface{}) (field **spanner.RequestOptions, requestTag bool) {
+	switch r := req.(type) {
+	case *spanner.ExecuteSqlRequest:
+		return &r.RequestOptions, true
+	case *spanner.ExecuteBatchDmlRequest:
+		return &r.RequestOptions, true
+	case *spanner.ReadRequest:
+		return &r.RequestOptions, true
+	case *spanner.BeginTransactionRequest:
+		return &r.RequestOptions, false
+	case *spanner.CommitRequest:
+		return &r.RequestOptions, false
+	default:
+		return nil, false
+	}
+}
+
+// inTransaction reports whether req belongs to a transaction, so its transaction tag is applicable.
+func inTransaction(req interface{}) bool {
+	switch r := req.(type) {
+	case *spanner.ExecuteSqlRequest:
+		return r.GetTransaction().GetSelector() != nil && r.GetTransaction().GetSingleUse() == nil
+	case *spanner.ExecuteBatchDmlRequest:
+		return true
+	case *spanner.ReadRequest:
+		return r.GetTransaction().GetSelector() != nil && r.GetTransaction().GetSingleUse() == nil
+	case *spanner.BeginTransactionRequest, *spanner.CommitRequest:
+		return true
+	default:
+		return false
+	}
+}
+
+func tagRequestWithBaggage(ctx context.Context, req interface{}, keys []string) {
+	requestOptions, requestTag := requestOptionsField(req)
+	if requestOptions == nil {
+		return
+	}
+	members := baggageTag(baggage.FromContext(ctx), keys)
+	if members == "" {
+		return
+	}
+	if requestTag {
+		if tag, ok := appendTag((*requestOptions).GetRequestTag(), members); ok {
+			if *requestOptions == nil {
+				*requestOptions = &spanner.RequestOptions{}
+			}
+			(*requestOptions).RequestTag = tag
+		}
+	}
+	if inTransaction(req) {
+		if tag, ok := appendTag((*requestOptions).GetTransactionTag(), members); ok {
+			if *requestOptions == nil {
+				*requestOptions = &spanner.RequestOptions{}
+			}
+			(*requestOptions).TransactionTag = tag
+		}
+	}
+}
+
+// baggageTag formats the members of keys in bag like "tenant=acme,feature=search".
+// It returns an empty string if no member is found or if a member can't be a part of a tag.
+func baggageTag(bag baggage.Baggage, keys []string) string {
+	var pairs []string
+	for _, key := range keys {
+		m := bag.Member(key)
+		if m.Key() == "" {
+			continue
+		}
+		pair := m.Key() + "=" + m.Value()
+		if !isPrintableASCII(pair) {
+			return ""
+		}
+		pairs = append(pairs, pair)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// appendTag appends s to tag after a space.
+// It returns false if tag already has s or if the result would exceed maxRequestTagLen.
+func appendTag(tag, s string) (string, bool) {
+	if strings.Contains(tag, s) {
+		return "", false
+	}
+	if tag != "" {
+		s = tag + " " + s
+	}
+	if len(s) > maxRequestTagLen {
+		return "", false
+	}
+	return s, true
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func tagRequestWithTraceID(ctx context.Context, req interface{}) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	requestOptions, requestTag := requestOptionsField(req)
+	if !requestTag {
+		return
+	}
+	tag := (*requestOptions).GetRequestTag()
+	if strings.Contains(tag, autoRequestTagPrefix) {
+		return
+	}
+	newTag := autoRequestTagPrefix + sc.TraceID().String()
+	if tag != "" {
+		newTag = tag + " " + newTag
+	}
+	if len(newTag) > maxRequestTagLen {
+		return
+	}
+	if *requestOptions == nil {
+		*requestOptions = &spanner.RequestOptions{}
+	}
+	(*requestOptions).RequestTag = newTag
+}
@@ -0,0 +1,176 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func contextWithBaggage(t *testing.T, kvs ...string) context.Context {
+	t.Helper()
+	var members []baggage.Member
+	for i := 0; i < len(kvs); i += 2 {
+		m, err := baggage.NewMember(kvs[i], kvs[i+1])
+		if err != nil {
+			t.Fatalf("baggage.NewMember(%q, %q) returned error: %v", kvs[i], kvs[i+1], err)
+		}
+		members = append(members, m)
+	}
+	bag, err := baggage.New(members...)
+	if err != nil {
+		t.Fatalf("baggage.New() returned error: %v", err)
+	}
+	return baggage.ContextWithBaggage(context.Background(), bag)
+}
+
+func TestWithBaggageRequestTags(t *testing.T) {
+	readWrite := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadWrite_{ReadWrite: &spanner.TransactionOptions_ReadWrite{}}}
+	for _, tt := range []struct {
+		desc               string
+		baggage            []string
+		req                requestOptionsGetter
+		wantRequestTag     string
+		wantTransactionTag string
+	}{
+		{"single-use query", []string{"tenant", "acme", "feature", "search", "user", "alice"},
+			&spanner.ExecuteSqlRequest{},
+			"tenant=acme,feature=search", ""},
+		{"query in a transaction", []string{"tenant", "acme"},
+			&spanner.ExecuteSqlRequest{Transaction: &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Id{Id: []byte("txn")}}},
+			"tenant=acme", "tenant=acme"},
+		{"existing tags are kept", []string{"tenant", "acme"},
+			&spanner.ReadRequest{
+				Transaction:    &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{Begin: readWrite}},
+				RequestOptions: &spanner.RequestOptions{RequestTag: "app=web", TransactionTag: "txn=update"},
+			},
+			"app=web tenant=acme", "txn=update tenant=acme"},
+		{"already tagged", []string{"tenant", "acme"},
+			&spanner.ExecuteBatchDmlRequest{RequestOptions: &spanner.RequestOptions{RequestTag: "tenant=acme", TransactionTag: "tenant=acme"}},
+			"tenant=acme", "tenant=acme"},
+		{"commit has only a transaction tag", []string{"feature", "search"},
+			&spanner.CommitRequest{},
+			"", "feature=search"},
+		{"too long", []string{"tenant", strings.Repeat("a", maxRequestTagLen)},
+			&spanner.ExecuteSqlRequest{},
+			"", ""},
+		{"no members", []string{"user", "alice"},
+			&spanner.ExecuteSqlRequest{},
+			"", ""},
+	} {
+		i := New(WithBaggageRequestTags("tenant", "feature"))
+		i.option.tagRequest(contextWithBaggage(t, tt.baggage...), tt.req)
+		if got := tt.req.GetRequestOptions().GetRequestTag(); got != tt.wantRequestTag {
+			t.Errorf("%s: request_tag = %q, want %q", tt.desc, got, tt.wantRequestTag)
+		}
+		if got := tt.req.GetRequestOptions().GetTransactionTag(); got != tt.wantTransactionTag {
+			t.Errorf("%s: transaction_tag = %q, want %q", tt.desc, got, tt.wantTransactionTag)
+		}
+	}
+}
+
+func TestWithRequestPriority(t *testing.T) {
+	for _, tt := range []struct {
+		priority spanner.RequestOptions_Priority
+		want     string
+	}{
+		{spanner.RequestOptions_PRIORITY_HIGH, "HIGH"},
+		{spanner.RequestOptions_PRIORITY_LOW, "LOW"},
+		{spanner.RequestOptions_PRIORITY_UNSPECIFIED, ""},
+	} {
+		req := &spanner.CommitRequest{RequestOptions: &spanner.RequestOptions{Priority: tt.priority}}
+		result := runUnary(t, commitMethod, req, &spanner.CommitResponse{}, nil, nil, nil, WithRequestPriority())
+		if result.err != nil {
+			t.Fatalf("interceptor returned error: %v", result.err)
+		}
+		v, ok := attributeValue(result.rpc.Attributes(), "spanner.request_priority")
+		if ok != (tt.want != "") || v.AsString() != tt.want {
+			t.Errorf("%v: spanner.request_priority = (%q, %v), want %q", tt.priority, v.AsString(), ok, tt.want)
+		}
+	}
+}
+
+func TestWithRequestTags(t *testing.T) {
+	for _, tt := range []struct {
+		desc               string
+		method             string
+		req                proto.Message
+		reply              proto.Message
+		wantRequestTag     string
+		wantTransactionTag string
+	}{
+		{"ExecuteSql", executeSQLMethod,
+			&spanner.ExecuteSqlRequest{Sql: "SELECT 1", RequestOptions: &spanner.RequestOptions{RequestTag: "app=web", TransactionTag: "txn=update"}},
+			&spanner.ResultSet{}, "app=web", "txn=update"},
+		{"Read", "/google.spanner.v1.Spanner/Read",
+			&spanner.ReadRequest{Table: "Singers", RequestOptions: &spanner.RequestOptions{RequestTag: "app=batch"}},
+			&spanner.ResultSet{}, "app=batch", ""},
+		{"Commit", commitMethod,
+			&spanner.CommitRequest{RequestOptions: &spanner.RequestOptions{TransactionTag: "txn=update"}},
+			&spanner.CommitResponse{}, "", "txn=update"},
+		{"no tags", commitMethod, &spanner.CommitRequest{}, &spanner.CommitResponse{}, "", ""},
+	} {
+		result := runUnary(t, tt.method, tt.req, tt.reply, nil, nil, nil, WithRequestTags())
+		if result.err != nil {
+			t.Fatalf("%s: interceptor returned error: %v", tt.desc, result.err)
+		}
+		attrs := result.rpc.Attributes()
+		for key, want := range map[string]string{
+			"spanner.request_tag":     tt.wantRequestTag,
+			"spanner.transaction_tag": tt.wantTransactionTag,
+		} {
+			if v, ok := attributeValue(attrs, key); ok != (want != "") || v.AsString() != want {
+				t.Errorf("%s: %s = (%q, %v), want %q", tt.desc, key, v.AsString(), ok, want)
+			}
+		}
+	}
+}
+
+func TestTagRequestWithTraceID(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatalf("invalid trace ID: %v", err)
+	}
+	valid := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+	const tag = "trace_id=4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, tt := range []struct {
+		desc string
+		ctx  context.Context
+		tag  string
+		want string
+	}{
+		{"empty tag", valid, "", tag},
+		{"short tag", valid, "app=web", "app=web " + tag},
+		{"8 characters", valid, "app=webs", "app=webs " + tag},
+		{"long tag", valid, "app=batch", "app=batch"},
+		{"sent again", valid, tag, tag},
+		{"invalid span context", context.Background(), "app=web", "app=web"},
+	} {
+		req := &spanner.ExecuteSqlRequest{RequestOptions: &spanner.RequestOptions{RequestTag: tt.tag}}
+		tagRequestWithTraceID(tt.ctx, req)
+		if got := req.GetRequestOptions().GetRequestTag(); got != tt.want {
+			t.Errorf("%s: request_tag = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+
+	// The tag is set on requests without RequestOptions, and is not duplicated when a request is sent again.
+	req := &spanner.ReadRequest{Table: "Singers"}
+	tagRequestWithTraceID(valid, req)
+	tagRequestWithTraceID(valid, req)
+	if got := req.GetRequestOptions().GetRequestTag(); got != tag {
+		t.Errorf("request_tag of a resent ReadRequest = %q, want %q", got, tag)
+	}
+	// Commit has no request tag.
+	commit := &spanner.CommitRequest{}
+	tagRequestWithTraceID(valid, commit)
+	if commit.GetRequestOptions() != nil {
+		t.Errorf("RequestOptions of CommitRequest = %v, want nil", commit.GetRequestOptions())
+	}
+}
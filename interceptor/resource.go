@@ -0,0 +1,130 @@
+package interceptor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestParamsHeader = "x-goog-request-params"
+
+// resourceName is the components of a Spanner resource name like projects/p/instances/i/databases/d/sessions/s.
+// Components which are not in the name are empty.
+type resourceName struct {
+	project  string
+	instance string
+	database string
+	session  string
+}
+
+// parseResourceName parses a resource name like projects/p/instances/i/databases/d/sessions/s.
+// Unknown collections are skipped, so a name of another resource under a database, or a prefix of it, is also accepted.
+func parseResourceName(name string) resourceName {
+	var r resourceName
+	parts := strings.Split(name, "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		switch parts[i] {
+		case "projects":
+			r.project = parts[i+1]
+		case "instances":
+			r.instance = parts[i+1]
+		case "databases":
+			r.database = parts[i+1]
+		case "sessions":
+			r.session = parts[i+1]
+		}
+	}
+	return r
+}
+
+// databaseName returns the database name like projects/p/instances/i/databases/d,
+// or an empty string if any of the components is missing.
+func (r resourceName) databaseName() string {
+	if r.project == "" || r.instance == "" || r.database == "" {
+		return ""
+	}
+	return "projects/" + r.project + "/instances/" + r.instance + "/databases/" + r.database
+}
+
+// requestParamsKeys are keys of x-goog-request-params which have resource names, in order of preference.
+var requestParamsKeys = []string{"session", "database", "name", "parent"}
+
+// parseRequestParams parses the resource name in a value of x-goog-request-params,
+// which is URL-encoded like "session=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fd%2Fsessions%2Fs".
+func parseRequestParams(params string) resourceName {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return resourceName{}
+	}
+	for _, key := range requestParamsKeys {
+		if name := values.Get(key); name != "" {
+			return parseResourceName(name)
+		}
+	}
+	return resourceName{}
+}
+
+// requestResourceName returns the resource name of req, e.g. its session,
+// or the one in x-goog-request-params of the outgoing metadata as a fallback.
+func requestResourceName(ctx context.Context, req interface{}) resourceName {
+	switch r := req.(type) {
+	case interface{ GetSession() string }:
+		if session := r.GetSession(); session != "" {
+			return parseResourceName(session)
+		}
+	case interface{ GetDatabase() string }:
+		if database := r.GetDatabase(); database != "" {
+			return parseResourceName(database)
+		}
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for _, params := range md.Get(requestParamsHeader) {
+			if name := parseRequestParams(params); name.database != "" {
+				return name
+			}
+		}
+	}
+	return resourceName{}
+}
+
+// WithDBNamespace sets db.namespace to the database name like projects/p/instances/i/databases/d,
+// so traces of applications using multiple databases can be filtered by database.
+// The database is taken from the session or database of requests, or from x-goog-request-params of the outgoing metadata,
+// which covers requests without a session like BatchCreateSessions.
+func WithDBNamespace() Option {
+	return WithRequestSpanDecorators(dbNamespaceSpanDecorator)
+}
+
+func dbNamespaceSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	if database := requestResourceName(ctx, req).databaseName(); database != "" {
+		span.SetAttributes(attribute.String("db.namespace", database))
+	}
+}
+
+// WithSessionName sets spanner.project, spanner.instance, spanner.database and spanner.session_id
+// from the session name of requests like projects/p/instances/i/databases/d/sessions/s,
+// or from x-goog-request-params of the outgoing metadata as a fallback.
+// Components which are not found are not set, e.g. spanner.session_id of BatchCreateSessions.
+func WithSessionName() Option {
+	return WithRequestSpanDecorators(sessionNameSpanDecorator)
+}
+
+func sessionNameSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	name := requestResourceName(ctx, req)
+	var attrs []attribute.KeyValue
+	for _, kv := range []struct{ key, value string }{
+		{"spanner.project", name.project},
+		{"spanner.instance", name.instance},
+		{"spanner.database", name.database},
+		{"spanner.session_id", name.session},
+	} {
+		if kv.value != "" {
+			attrs = append(attrs, attribute.String(kv.key, kv.value))
+		}
+	}
+	span.SetAttributes(attrs...)
+}
@@ -0,0 +1,103 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseResourceName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want resourceName
+	}{
+		{"projects/p/instances/i/databases/d/sessions/s", resourceName{"p", "i", "d", "s"}},
+		{"projects/p/instances/i/databases/d", resourceName{"p", "i", "d", ""}},
+		{"projects/p/instances/i/databases/d/operations/o", resourceName{"p", "i", "d", ""}},
+		{"projects/p/instances/i", resourceName{"p", "i", "", ""}},
+		{"databases", resourceName{}},
+		{"", resourceName{}},
+	} {
+		if got := parseResourceName(tt.name); got != tt.want {
+			t.Errorf("parseResourceName(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRequestParams(t *testing.T) {
+	for _, tt := range []struct {
+		params string
+		want   resourceName
+	}{
+		{"session=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fd%2Fsessions%2Fs", resourceName{"p", "i", "d", "s"}},
+		{"database=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fd", resourceName{"p", "i", "d", ""}},
+		{"other=x&database=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fd", resourceName{"p", "i", "d", ""}},
+		{"other=x", resourceName{}},
+		{"%zz", resourceName{}},
+	} {
+		if got := parseRequestParams(tt.params); got != tt.want {
+			t.Errorf("parseRequestParams(%q) = %+v, want %+v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestWithDBNamespace(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		session string
+		params  string
+		want    string
+	}{
+		{"session in the request", "projects/p/instances/i/databases/d/sessions/s", "", "projects/p/instances/i/databases/d"},
+		{"session in the request takes precedence", "projects/p/instances/i/databases/d/sessions/s",
+			"session=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fother%2Fsessions%2Fs", "projects/p/instances/i/databases/d"},
+		{"request params", "", "database=projects%2Fp%2Finstances%2Fi%2Fdatabases%2Fd", "projects/p/instances/i/databases/d"},
+		{"nothing", "", "", ""},
+	} {
+		result := runStreamInContext(t, func(ctx context.Context, tracer trace.Tracer) context.Context {
+			if tt.params == "" {
+				return ctx
+			}
+			return metadata.AppendToOutgoingContext(ctx, requestParamsHeader, tt.params)
+		}, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Session: tt.session, Sql: "SELECT 1"}, &fakeClientStream{
+			responses: []proto.Message{&spanner.PartialResultSet{}},
+		}, WithDBNamespace())
+		if result.err != nil {
+			t.Fatalf("%s: stream returned error: %v", tt.desc, result.err)
+		}
+		v, ok := attributeValue(result.rpc.Attributes(), "db.namespace")
+		if ok != (tt.want != "") || v.AsString() != tt.want {
+			t.Errorf("%s: db.namespace = (%q, %v), want %q", tt.desc, v.AsString(), ok, tt.want)
+		}
+	}
+}
+
+func TestWithSessionName(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		req    proto.Message
+		reply  proto.Message
+		want   map[string]string
+	}{
+		{executeSQLMethod, &spanner.ExecuteSqlRequest{Session: "projects/p/instances/i/databases/d/sessions/s", Sql: "SELECT 1"}, &spanner.ResultSet{},
+			map[string]string{"spanner.project": "p", "spanner.instance": "i", "spanner.database": "d", "spanner.session_id": "s"}},
+		{"/google.spanner.v1.Spanner/BatchCreateSessions", &spanner.BatchCreateSessionsRequest{Database: "projects/p/instances/i/databases/d"}, &spanner.BatchCreateSessionsResponse{},
+			map[string]string{"spanner.project": "p", "spanner.instance": "i", "spanner.database": "d"}},
+	} {
+		result := runUnary(t, tt.method, tt.req, tt.reply, nil, nil, nil, WithSessionName())
+		if result.err != nil {
+			t.Fatalf("%s returned error: %v", tt.method, result.err)
+		}
+		attrs := result.rpc.Attributes()
+		for _, key := range []string{"spanner.project", "spanner.instance", "spanner.database", "spanner.session_id"} {
+			v, ok := attributeValue(attrs, key)
+			if want, wantOk := tt.want[key]; ok != wantOk || v.AsString() != want {
+				t.Errorf("%s: %s = (%q, %v), want (%q, %v)", tt.method, key, v.AsString(), ok, want, wantOk)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// FromRowIterator runs stats decorators and generates plan spans under the span in ctx
+// from an already iterated RowIterator of QueryWithStats, without the gRPC interceptors.
+// It shares the state of i with the interceptors, e.g. WithPerTraceSpanBudget and WithFirstPlanPerFingerprint.
+// It returns an error if QueryStats can't be converted.
+func (i *Interceptors) FromRowIterator(ctx context.Context, it *spanner.RowIterator) error {
+	queryStats, err := structpb.NewStruct(it.QueryStats)
+	if err != nil {
+		return fmt.Errorf("invalid QueryStats: %w", err)
+	}
+	stats := &sppb.ResultSetStats{
+		QueryPlan:  it.QueryPlan,
+		QueryStats: queryStats,
+	}
+
+	i.option.decorateStats(ctx, i.option.spanFromContext(ctx), stats)
+	return nil
+}
+
+// FromRowIterator is a shorthand of New(opts...).FromRowIterator(ctx, it).
+// Stateful options like WithPerTraceSpanBudget and WithFirstPlanPerFingerprint have no effect across calls
+// because the state is not shared, so use Interceptors.FromRowIterator for them.
+func FromRowIterator(ctx context.Context, it *spanner.RowIterator, opts ...Option) error {
+	return New(opts...).FromRowIterator(ctx, it)
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFromRowIterator(t *testing.T) {
+	stats := mustStats(t, queryStatsWithPlan)
+	for _, tt := range []struct {
+		desc       string
+		queryStats map[string]interface{}
+		wantErr    bool
+	}{
+		{"valid", stats.GetQueryStats().AsMap(), false},
+		{"invalid QueryStats", map[string]interface{}{"query_text": make(chan int)}, true},
+	} {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, sp := tp.Tracer("test").Start(context.Background(), "query")
+		it := &spanner.RowIterator{QueryPlan: stats.GetQueryPlan(), QueryStats: tt.queryStats}
+		err := FromRowIterator(ctx, it, WithDefaultDecorators(), WithTracerProvider(tp))
+		sp.End()
+
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: FromRowIterator returned %v, want error: %v", tt.desc, err, tt.wantErr)
+		}
+		ended := recorder.Ended()
+		query := ended[len(ended)-1]
+		if tt.wantErr {
+			if len(ended) != 1 || len(query.Attributes()) != 0 {
+				t.Errorf("%s: spans = %q with attributes %v, want only the query span without attributes", tt.desc, spanNames(ended), query.Attributes())
+			}
+			continue
+		}
+		if v, _ := attributeValue(query.Attributes(), "query_text"); v.AsString() != "SELECT * FROM Singers" {
+			t.Errorf("%s: query_text = %q, want %q", tt.desc, v.AsString(), "SELECT * FROM Singers")
+		}
+		wantNames := []string{"1: Table Scan (Table: Singers)", "0: Distributed Union"}
+		if got := spanNames(ended[:len(ended)-1]); !equalStrings(got, wantNames) {
+			t.Fatalf("%s: plan spans = %q, want %q", tt.desc, got, wantNames)
+		}
+		if root := ended[len(ended)-2]; root.Parent().SpanID() != query.SpanContext().SpanID() {
+			t.Errorf("%s: %q is not a child of the span in the context", tt.desc, root.Name())
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package interceptor
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"google.golang.org/grpc/status"
+)
+
+// rpcAttributes returns attributes of the OpenTelemetry RPC semantic conventions for a full method name
+// like /google.spanner.v1.Spanner/ExecuteStreamingSql.
+func rpcAttributes(fullMethod string) []attribute.KeyValue {
+	service, method := split2(strings.TrimPrefix(fullMethod, "/"), "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")}
+	if service != "" {
+		attrs = append(attrs, semconv.RPCServiceKey.String(service))
+	}
+	if method != "" {
+		attrs = append(attrs, semconv.RPCMethodKey.String(method))
+	}
+	return attrs
+}
+
+// rpcStatusCodeAttribute returns rpc.grpc.status_code for err. nil err is OK.
+func rpcStatusCodeAttribute(err error) attribute.KeyValue {
+	return semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err)))
+}
@@ -0,0 +1,57 @@
+package interceptor
+
+import (
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithRPCSemanticConventions(t *testing.T) {
+	abortedErr := status.Error(grpccodes.Aborted, "transaction aborted")
+	for _, tt := range []struct {
+		desc     string
+		unary    bool
+		err      error
+		wantCode grpccodes.Code
+	}{
+		{"stream OK", false, nil, grpccodes.OK},
+		{"stream error", false, abortedErr, grpccodes.Aborted},
+		{"unary OK", true, nil, grpccodes.OK},
+		{"unary error", true, abortedErr, grpccodes.Aborted},
+	} {
+		var rpc sdktrace.ReadOnlySpan
+		var err error
+		method := executeSQLMethod
+		if tt.unary {
+			result := runUnary(t, method, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &spanner.ResultSet{}, nil, nil, tt.err, WithRPCSemanticConventions())
+			rpc, err = result.rpc, result.err
+		} else {
+			method = executeStreamingSQLMethod
+			result := runStream(t, method, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}, &fakeClientStream{
+				responses: []proto.Message{&spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}}},
+				err:       tt.err,
+			}, WithRPCSemanticConventions())
+			rpc, err = result.rpc, result.err
+		}
+		if err != tt.err {
+			t.Fatalf("%s: returned %v, want %v", tt.desc, err, tt.err)
+		}
+		attrs := rpc.Attributes()
+		for key, want := range map[string]string{
+			"rpc.system":  "grpc",
+			"rpc.service": "google.spanner.v1.Spanner",
+			"rpc.method":  method[len("/google.spanner.v1.Spanner/"):],
+		} {
+			if v, _ := attributeValue(attrs, key); v.AsString() != want {
+				t.Errorf("%s: %s = %q, want %q", tt.desc, key, v.AsString(), want)
+			}
+		}
+		if v, ok := attributeValue(attrs, "rpc.grpc.status_code"); !ok || v.AsInt64() != int64(tt.wantCode) {
+			t.Errorf("%s: rpc.grpc.status_code = (%v, %v), want %v", tt.desc, v.AsInt64(), ok, int64(tt.wantCode))
+		}
+	}
+}
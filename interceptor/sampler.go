@@ -0,0 +1,95 @@
+package interceptor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// queryFingerprint returns a fingerprint of the query text in stats which ignores differences in whitespace,
+// or "" if stats has no query_text.
+func queryFingerprint(stats *spanner.ResultSetStats) string {
+	text := queryStatsField(stats, "query_text").GetStringValue()
+	if text == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(strings.Fields(text), " ")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// planSampler remembers fingerprints seen within interval and holds at most max fingerprints.
+// When it is full, the oldest fingerprint is evicted. It is safe for concurrent use.
+type planSampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	max      int
+	seen     map[string]time.Time
+	order    []string
+}
+
+func newPlanSampler(interval time.Duration, max int) *planSampler {
+	return &planSampler{
+		interval: interval,
+		max:      max,
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// sample reports whether fingerprint is not seen within the interval before now, and records it if so.
+func (s *planSampler) sample(fingerprint string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// order is sorted by the time seen, so expired fingerprints are at the front.
+	for len(s.order) > 0 && now.Sub(s.seen[s.order[0]]) >= s.interval {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+	if _, ok := s.seen[fingerprint]; ok {
+		return false
+	}
+	if len(s.order) >= s.max {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.seen[fingerprint] = now
+	s.order = append(s.order, fingerprint)
+	return true
+}
+
+// WithFirstPlanPerFingerprint emits plan spans only for the first query of each fingerprint per interval.
+// The fingerprint is computed from query_text with whitespace normalized and is set as spanner.query_fingerprint.
+// Stats decorators are applied to every query, and plan.sampled_out is set on the RPC span when plan spans are skipped.
+// Queries without query_text are not sampled out.
+// At most 1024 fingerprints are remembered; the oldest one is evicted when full, so its next plan is emitted early.
+// It is safe to share an interceptor with this option between goroutines.
+func WithFirstPlanPerFingerprint(interval time.Duration) Option {
+	return func(o *interceptorOption) {
+		o.planSampler = newPlanSampler(interval, defaultMaxTrackedFingerprints)
+	}
+}
+
+const defaultMaxTrackedFingerprints = 1024
+
+// samplePlan reports whether the plan spans of stats should be emitted by the plan sampler.
+func (o *interceptorOption) samplePlan(sp trace.Span, stats *spanner.ResultSetStats) bool {
+	if o.planSampler == nil {
+		return true
+	}
+	fingerprint := queryFingerprint(stats)
+	if fingerprint == "" {
+		return true
+	}
+	sp.SetAttributes(attribute.String("spanner.query_fingerprint", fingerprint))
+	if !o.planSampler.sample(fingerprint, o.now()) {
+		sp.SetAttributes(attribute.Bool("plan.sampled_out", true))
+		return false
+	}
+	return true
+}
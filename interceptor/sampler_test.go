@@ -0,0 +1,74 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPlanSampler(t *testing.T) {
+	t0 := time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)
+	s := newPlanSampler(time.Minute, 2)
+	for _, tt := range []struct {
+		fingerprint string
+		now         time.Time
+		want        bool
+	}{
+		{"a", t0, true},
+		{"a", t0.Add(30 * time.Second), false},
+		{"b", t0.Add(30 * time.Second), true},
+		{"a", t0.Add(time.Minute), true},
+		// c evicts b, the oldest one, because the sampler is full.
+		{"c", t0.Add(time.Minute), true},
+		{"b", t0.Add(time.Minute), true},
+	} {
+		if got := s.sample(tt.fingerprint, tt.now); got != tt.want {
+			t.Errorf("sample(%q, %v) = %v, want %v", tt.fingerprint, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestWithFirstPlanPerFingerprint(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	now := time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)
+	i := New(WithFirstPlanPerFingerprint(time.Minute), WithTracerProvider(tp), WithClock(func() time.Time { return now }))
+
+	var sampledOut int
+	for n, queryText := range []string{
+		"SELECT * FROM Singers",
+		"SELECT *\n  FROM Singers",
+		"SELECT * FROM Albums",
+		"SELECT * FROM Singers",
+	} {
+		if n == 3 {
+			now = now.Add(time.Minute)
+		}
+		stats := mustStats(t, queryStatsWithPlan)
+		stats.GetQueryStats().GetFields()["query_text"] = structpb.NewStringValue(queryText)
+		ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+		i.option.decorateStats(contextWithMethod(ctx, executeStreamingSQLMethod), sp, stats)
+		sp.End()
+		if v, _ := attributeValue(recorder.Ended()[len(recorder.Ended())-1].Attributes(), "plan.sampled_out"); v.AsBool() {
+			sampledOut++
+		}
+	}
+
+	// The second query is the same as the first one within the interval, and the last one is after the interval.
+	if sampledOut != 1 {
+		t.Errorf("sampled out %d queries, want 1", sampledOut)
+	}
+	var planRoots int
+	for _, span := range recorder.Ended() {
+		if span.Name() == "0: Distributed Union" {
+			planRoots++
+		}
+	}
+	if planRoots != 3 {
+		t.Errorf("%d plans are emitted, want 3", planRoots)
+	}
+}
@@ -0,0 +1,120 @@
+package interceptor
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// parseStatInt parses a numeric query stats value, which can be a number or a string like "123" or "1,234 rows".
+func parseStatInt(v *structpb.Value) (int64, bool) {
+	switch v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return int64(v.GetNumberValue()), true
+	case *structpb.Value_StringValue:
+		n, err := parseGroupedInt(v.GetStringValue())
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func queryStatsField(stats *spanner.ResultSetStats, key string) *structpb.Value {
+	return stats.GetQueryStats().GetFields()[key]
+}
+
+// parseGroupedInt parses an integer which may contain comma or space digit grouping and a trailing unit,
+// e.g. "1,234,567", "12 345" and "1,234 rows".
+func parseGroupedInt(s string) (int64, error) {
+	value, _ := splitUnit(s)
+	return strconv.ParseInt(stripDigitGrouping(value), 10, 64)
+}
+
+// parseStatMillis parses a duration stat like "1.23 msecs" or "1,234.56 msecs" in milliseconds.
+func parseStatMillis(s string) (float64, bool) {
+	value, unit := splitUnit(s)
+	f, err := strconv.ParseFloat(stripDigitGrouping(value), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch unit {
+	case "msecs", "ms", "":
+		return f, true
+	case "usecs", "us":
+		return f / 1000, true
+	case "secs", "s":
+		return f * 1000, true
+	default:
+		return 0, false
+	}
+}
+
+// splitUnit splits a stat like "1,234 rows" into the number part and the trailing unit.
+func splitUnit(s string) (value, unit string) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, "0123456789")
+	return s[:i+1], strings.TrimSpace(s[i+1:])
+}
+
+// stripDigitGrouping removes comma and space digit grouping separators.
+func stripDigitGrouping(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', ' ', '\u00a0', '\u202f':
+			return -1
+		default:
+			return r
+		}
+	}, s)
+}
+
+// WithAllQueryStatsAttributes sets every scalar field of query stats as an attribute keyed by prefix+field,
+// e.g. "spanner.stats.rows_scanned", so fields newly introduced by Spanner are recorded without code changes.
+// Numbers are set as int64 if they are integral or float64 otherwise, and strings and bools are set as they are.
+// query_text is excluded because of its cardinality unless includeQueryText is true.
+func WithAllQueryStatsAttributes(prefix string, includeQueryText bool) Option {
+	return func(o *interceptorOption) {
+		o.statsSpanDecorators = append(o.statsSpanDecorators, func(ctx context.Context, span trace.Span, stats *spanner.ResultSetStats) {
+			span.SetAttributes(queryStatsAttributes(prefix, stats, includeQueryText)...)
+		})
+	}
+}
+
+func queryStatsAttributes(prefix string, stats *spanner.ResultSetStats, includeQueryText bool) []attribute.KeyValue {
+	fields := stats.GetQueryStats().GetFields()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k == "query_text" && !includeQueryText {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var attrs []attribute.KeyValue
+	for _, k := range keys {
+		key := prefix + k
+		switch v := fields[k]; v.GetKind().(type) {
+		case *structpb.Value_NumberValue:
+			if n := v.GetNumberValue(); n == float64(int64(n)) {
+				attrs = append(attrs, attribute.Int64(key, int64(n)))
+			} else {
+				attrs = append(attrs, attribute.Float64(key, n))
+			}
+		case *structpb.Value_StringValue:
+			attrs = append(attrs, attribute.String(key, v.GetStringValue()))
+		case *structpb.Value_BoolValue:
+			attrs = append(attrs, attribute.Bool(key, v.GetBoolValue()))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,93 @@
+package interceptor
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestParseGroupedInt(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  int64
+	}{
+		{"1234567", 1234567},
+		{"1,234,567", 1234567},
+		{"12 345", 12345},
+		{"1,234 rows", 1234},
+	} {
+		got, err := parseGroupedInt(tt.input)
+		if err != nil {
+			t.Errorf("parseGroupedInt(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseGroupedInt(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatMillis(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		want   float64
+		wantOk bool
+	}{
+		{"1.23 msecs", 1.23, true},
+		{"1,234.56 msecs", 1234.56, true},
+		{"1234.56 msecs", 1234.56, true},
+		{"1 234.5 msecs", 1234.5, true},
+		{"1500 usecs", 1.5, true},
+		{"2 secs", 2000, true},
+		{"12", 12, true},
+		{"1.23 hours", 0, false},
+		{"msecs", 0, false},
+		{"", 0, false},
+	} {
+		got, ok := parseStatMillis(tt.input)
+		if ok != tt.wantOk || got != tt.want {
+			t.Errorf("parseStatMillis(%q) = (%v, %v), want (%v, %v)", tt.input, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestQueryStatsAttributes(t *testing.T) {
+	stats := mustStats(t, `{"queryStats": {
+	  "query_text": "SELECT 1",
+	  "rows_returned": "1",
+	  "rows_scanned": 10,
+	  "cpu_time_ratio": 0.25,
+	  "data_boost_enabled": false,
+	  "nested": {"ignored": true},
+	  "nothing": null
+	}}`)
+	for _, tt := range []struct {
+		includeQueryText bool
+		want             []attribute.KeyValue
+	}{
+		{false, []attribute.KeyValue{
+			attribute.Float64("stats.cpu_time_ratio", 0.25),
+			attribute.Bool("stats.data_boost_enabled", false),
+			attribute.String("stats.rows_returned", "1"),
+			attribute.Int64("stats.rows_scanned", 10),
+		}},
+		{true, []attribute.KeyValue{
+			attribute.Float64("stats.cpu_time_ratio", 0.25),
+			attribute.Bool("stats.data_boost_enabled", false),
+			attribute.String("stats.query_text", "SELECT 1"),
+			attribute.String("stats.rows_returned", "1"),
+			attribute.Int64("stats.rows_scanned", 10),
+		}},
+	} {
+		got := queryStatsAttributes("stats.", stats, tt.includeQueryText)
+		if len(got) != len(tt.want) {
+			t.Errorf("queryStatsAttributes(includeQueryText: %v) = %v, want %v", tt.includeQueryText, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("queryStatsAttributes(includeQueryText: %v)[%d] = %v, want %v", tt.includeQueryText, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// StatsHandler is a gRPC stats.Handler which decorates spans like the interceptors,
+// so it can coexist with other chained interceptors and observe headers when they arrive.
+// Install it by grpc.WithStatsHandler or StatsHandlerDialOption.
+//
+// It supports request, header, trailer, result set metadata, stats and post decorators, plan spans,
+// client overhead, static attributes, the RPC semantic conventions and WithQueryMode.
+// The following options are only supported by the interceptors, and ignored by StatsHandler:
+// WithAutoRequestTag and WithBaggageRequestTags can't rewrite requests because they are observed after encoding,
+// WithCreateSpan and WithTransactionSpans don't start spans because StatsHandler always decorates the span in the context,
+// WithResumeDeduplication doesn't skip stats of resumed streams, and WithDBSemanticConventions doesn't set server.address.
+// Options depending on the message flow of streams, i.e. WithPartialResultSetCount, WithBytesReturned,
+// WithReceivedMessageStats, WithTimeToFirstRow, WithOperation and WithTransactionRetryTracking, are not supported either.
+type StatsHandler struct {
+	option *interceptorOption
+}
+
+// StatsHandler returns a StatsHandler sharing the configuration of i.
+func (i *Interceptors) StatsHandler() *StatsHandler {
+	return &StatsHandler{option: &i.option}
+}
+
+// NewStatsHandler is a shorthand of New(opts...).StatsHandler().
+func NewStatsHandler(opts ...Option) *StatsHandler {
+	return New(opts...).StatsHandler()
+}
+
+// StatsHandlerDialOption returns a dial option which installs h.
+func StatsHandlerDialOption(h *StatsHandler) grpc.DialOption {
+	return grpc.WithStatsHandler(h)
+}
+
+type statsHandlerStateKey struct{}
+
+// statsHandlerState is the state of a RPC between TagRPC and HandleRPC.
+type statsHandlerState struct {
+	start          time.Time
+	elapsedTimeMs  float64
+	hasElapsedTime bool
+	// request is the last request, whose QueryMode is used by plan spans.
+	request interface{}
+}
+
+// TagRPC implements stats.Handler.
+func (h *StatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if !h.option.allowMethod(info.FullMethodName) {
+		return ctx
+	}
+	ctx = contextWithMethod(ctx, info.FullMethodName)
+	return context.WithValue(ctx, statsHandlerStateKey{}, &statsHandlerState{})
+}
+
+// HandleRPC implements stats.Handler.
+func (h *StatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	state, ok := ctx.Value(statsHandlerStateKey{}).(*statsHandlerState)
+	if !ok || !rs.IsClient() {
+		return
+	}
+	o := h.option
+	sp := o.spanFromContext(ctx)
+	switch rs := rs.(type) {
+	case *stats.Begin:
+		state.start = o.now()
+		o.setStaticAttributes(sp)
+		if o.rpcSemanticConventions {
+			sp.SetAttributes(rpcAttributes(MethodFromContext(ctx))...)
+		}
+	case *stats.OutPayload:
+		state.request = rs.Payload
+		o.decorateRequest(ctx, sp, rs.Payload)
+	case *stats.InHeader:
+		o.decorateHeader(ctx, sp, rs.Header)
+	case *stats.InTrailer:
+		o.decorateTrailer(ctx, sp, rs.Trailer)
+	case *stats.InPayload:
+		var resultSetStats *spanner.ResultSetStats
+		var resultSetMetadata *spanner.ResultSetMetadata
+		switch m := rs.Payload.(type) {
+		case *spanner.PartialResultSet:
+			resultSetStats, resultSetMetadata = m.GetStats(), m.GetMetadata()
+		case *spanner.ResultSet:
+			resultSetStats, resultSetMetadata = m.GetStats(), m.GetMetadata()
+		}
+		if resultSetMetadata != nil {
+			o.decorateResultSetMetadata(ctx, sp, resultSetMetadata)
+		}
+		if resultSetStats != nil {
+			if ms, ok := o.elapsedTimeMillis(resultSetStats); ok {
+				state.elapsedTimeMs, state.hasElapsedTime = ms, true
+			}
+			o.decorateStats(contextWithQueryMode(ctx, state.request), sp, resultSetStats)
+		}
+	case *stats.End:
+		if o.rpcSemanticConventions {
+			sp.SetAttributes(rpcStatusCodeAttribute(rs.Error))
+		}
+		o.recordError(sp, rs.Error)
+		if rs.Error == nil && o.clientOverhead && state.hasElapsedTime {
+			o.setClientOverhead(sp, state.start, state.elapsedTimeMs)
+		}
+		o.decoratePost(ctx, sp)
+	}
+}
+
+// TagConn implements stats.Handler.
+func (h *StatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.
+func (h *StatsHandler) HandleConn(ctx context.Context, cs stats.ConnStats) {}
+
+var _ stats.Handler = (*StatsHandler)(nil)
@@ -0,0 +1,99 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatsHandler(t *testing.T) {
+	abortedErr := status.Error(grpccodes.Aborted, "transaction aborted")
+	for _, tt := range []struct {
+		desc      string
+		method    string
+		err       error
+		wantAttrs bool
+	}{
+		{"OK", executeStreamingSQLMethod, nil, true},
+		{"error", executeStreamingSQLMethod, abortedErr, true},
+		{"filtered method", streamingReadMethod, nil, false},
+	} {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		h := NewStatsHandler(WithTracerProvider(tp), WithDefaultDecorators(), WithQueryMode(), WithRouteToLeader(), WithRPCSemanticConventions(),
+			WithAttributes(attribute.String("app", "test")), WithMethodFilter(func(method string) bool { return method == executeStreamingSQLMethod }))
+
+		ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+		ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: tt.method})
+		for _, rs := range []stats.RPCStats{
+			&stats.Begin{Client: true},
+			&stats.OutPayload{Client: true, Payload: &spanner.ExecuteSqlRequest{Sql: "SELECT * FROM Singers", QueryMode: spanner.ExecuteSqlRequest_PROFILE}},
+			&stats.InHeader{Client: true, Header: metadata.Pairs(routeToLeaderHeader, "true")},
+			&stats.InPayload{Client: true, Payload: &spanner.PartialResultSet{Metadata: &spanner.ResultSetMetadata{}, Stats: mustStats(t, queryStatsWithPlan)}},
+			&stats.End{Client: true, Error: tt.err},
+		} {
+			h.HandleRPC(ctx, rs)
+		}
+		sp.End()
+
+		ended := recorder.Ended()
+		rpc := ended[len(ended)-1]
+		attrs := rpc.Attributes()
+		for key, want := range map[string]attribute.Value{
+			"app":                     attribute.StringValue("test"),
+			"spanner.query_mode":      attribute.StringValue("PROFILE"),
+			"spanner.route_to_leader": attribute.BoolValue(true),
+			"query_text":              attribute.StringValue("SELECT * FROM Singers"),
+			"rpc.method":              attribute.StringValue("ExecuteStreamingSql"),
+			"rpc.grpc.status_code":    attribute.Int64Value(int64(status.Code(tt.err))),
+		} {
+			v, ok := attributeValue(attrs, key)
+			if !tt.wantAttrs {
+				if ok {
+					t.Errorf("%s: %s is set", tt.desc, key)
+				}
+				continue
+			}
+			if v != want {
+				t.Errorf("%s: %s = %v, want %v", tt.desc, key, v.Emit(), want.Emit())
+			}
+		}
+
+		wantNames := []string{"1: Table Scan (Table: Singers)", "0: Distributed Union"}
+		if !tt.wantAttrs {
+			wantNames = nil
+		}
+		if got := spanNames(ended[:len(ended)-1]); !equalStrings(got, wantNames) {
+			t.Fatalf("%s: plan spans = %q, want %q", tt.desc, got, wantNames)
+		}
+		if tt.wantAttrs {
+			// The QueryMode of the request is also set on the root plan span.
+			if v, _ := attributeValue(ended[len(ended)-2].Attributes(), "spanner.query_mode"); v.AsString() != "PROFILE" {
+				t.Errorf("%s: spanner.query_mode of the root plan span = %q, want %q", tt.desc, v.AsString(), "PROFILE")
+			}
+		}
+	}
+}
+
+func TestStatsHandlerIgnoresServerSide(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	h := NewStatsHandler(WithTracerProvider(tp), WithDefaultDecorators())
+
+	ctx, sp := tp.Tracer("test").Start(context.Background(), "rpc")
+	ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: executeStreamingSQLMethod})
+	h.HandleRPC(ctx, &stats.InPayload{Payload: &spanner.PartialResultSet{Stats: mustStats(t, queryStatsWithPlan)}})
+	sp.End()
+
+	if ended := recorder.Ended(); len(ended) != 1 || len(ended[0].Attributes()) != 0 {
+		t.Errorf("spans = %q, want only the RPC span without attributes", spanNames(ended))
+	}
+}
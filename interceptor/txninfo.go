@@ -0,0 +1,71 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithTransactionInfo sets attributes describing the transaction of the request:
+// spanner.single_use, spanner.staleness and spanner.read_timestamp.
+func WithTransactionInfo() Option {
+	return func(o *interceptorOption) {
+		WithRequestSpanDecorators(transactionInfoSpanDecorator)(o)
+		WithResultSetMetadataSpanDecorators(readTimestampSpanDecorator)(o)
+	}
+}
+
+// transactionInfoSpanDecorator sets spanner.single_use and spanner.staleness from the transaction selector of the request.
+//
+// The single-use-ness can't be inferred from the response alone: ResultSetMetadata.Transaction is only returned
+// when the request begins a transaction (id is set) or a single-use read-only transaction returns its read timestamp
+// (id is empty), and nothing is returned for an existing transaction id. So it is inferred from the selector:
+// spanner.single_use is true for single_use, and false for begin and id, which establish or use a multi-use transaction.
+// Nothing is set if the request has no selector.
+func transactionInfoSpanDecorator(ctx context.Context, span trace.Span, req interface{}) {
+	r, ok := req.(interface {
+		GetTransaction() *spanner.TransactionSelector
+	})
+	if !ok {
+		return
+	}
+	selector := r.GetTransaction()
+	switch {
+	case selector.GetSingleUse() != nil:
+		span.SetAttributes(attribute.Bool("spanner.single_use", true))
+		if staleness := readOnlyStaleness(selector.GetSingleUse().GetReadOnly()); staleness != "" {
+			span.SetAttributes(attribute.String("spanner.staleness", staleness))
+		}
+	case selector.GetBegin() != nil:
+		span.SetAttributes(attribute.Bool("spanner.single_use", false))
+		if staleness := readOnlyStaleness(selector.GetBegin().GetReadOnly()); staleness != "" {
+			span.SetAttributes(attribute.String("spanner.staleness", staleness))
+		}
+	case len(selector.GetId()) > 0:
+		span.SetAttributes(attribute.Bool("spanner.single_use", false))
+	}
+}
+
+// readOnlyStaleness formats the timestamp bound of read-only transaction options, e.g. "strong" and "exact_staleness=10s".
+func readOnlyStaleness(options *spanner.TransactionOptions_ReadOnly) string {
+	switch {
+	case options == nil:
+		return ""
+	case options.GetStrong():
+		return "strong"
+	case options.GetExactStaleness() != nil:
+		return "exact_staleness=" + options.GetExactStaleness().AsDuration().String()
+	case options.GetMaxStaleness() != nil:
+		return "max_staleness=" + options.GetMaxStaleness().AsDuration().String()
+	case options.GetReadTimestamp() != nil:
+		return "read_timestamp=" + options.GetReadTimestamp().AsTime().Format(time.RFC3339Nano)
+	case options.GetMinReadTimestamp() != nil:
+		return "min_read_timestamp=" + options.GetMinReadTimestamp().AsTime().Format(time.RFC3339Nano)
+	default:
+		// No timestamp bound means strong reads.
+		return "strong"
+	}
+}
@@ -0,0 +1,44 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestWithTransactionInfo(t *testing.T) {
+	staleRead := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadOnly_{ReadOnly: &spanner.TransactionOptions_ReadOnly{
+		TimestampBound: &spanner.TransactionOptions_ReadOnly_ExactStaleness{ExactStaleness: durationpb.New(10 * time.Second)},
+	}}}
+	readWrite := &spanner.TransactionOptions{Mode: &spanner.TransactionOptions_ReadWrite_{ReadWrite: &spanner.TransactionOptions_ReadWrite{}}}
+	for _, tt := range []struct {
+		desc          string
+		selector      *spanner.TransactionSelector
+		wantSingleUse *bool
+		wantStaleness string
+	}{
+		{"single-use", &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_SingleUse{SingleUse: staleRead}}, proto.Bool(true), "exact_staleness=10s"},
+		{"begin", &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Begin{Begin: readWrite}}, proto.Bool(false), ""},
+		{"existing transaction", &spanner.TransactionSelector{Selector: &spanner.TransactionSelector_Id{Id: []byte("txn")}}, proto.Bool(false), ""},
+		{"no selector", nil, nil, ""},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			result := runStream(t, executeStreamingSQLMethod, &spanner.ExecuteSqlRequest{Sql: "SELECT 1", Transaction: tt.selector}, &fakeClientStream{}, WithTransactionInfo())
+
+			attrs := result.rpc.Attributes()
+			v, ok := attributeValue(attrs, "spanner.single_use")
+			switch {
+			case tt.wantSingleUse == nil && ok:
+				t.Errorf("spanner.single_use = %v, want unset", v.AsBool())
+			case tt.wantSingleUse != nil && (!ok || v.AsBool() != *tt.wantSingleUse):
+				t.Errorf("spanner.single_use = %v (set: %v), want %v", v.AsBool(), ok, *tt.wantSingleUse)
+			}
+			if v, _ := attributeValue(attrs, "spanner.staleness"); v.AsString() != tt.wantStaleness {
+				t.Errorf("spanner.staleness = %q, want %q", v.AsString(), tt.wantStaleness)
+			}
+		})
+	}
+}
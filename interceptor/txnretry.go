@@ -0,0 +1,65 @@
+package interceptor
+
+import (
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMaxTrackedTransactions = 1024
+
+// txnRetryTracker counts retried attempts of RPCs per transaction id.
+// It is safe for concurrent use. At most maxTransactions transactions are tracked;
+// when it is full, the oldest tracked transaction is evicted.
+// Transactions are also evicted when Commit or Rollback is observed.
+type txnRetryTracker struct {
+	counts *boundedCounter
+}
+
+func newTxnRetryTracker(maxTransactions int) *txnRetryTracker {
+	if maxTransactions <= 0 {
+		maxTransactions = defaultMaxTrackedTransactions
+	}
+	return &txnRetryTracker{counts: newBoundedCounter(maxTransactions)}
+}
+
+// isRetryable reports whether an RPC failed with err is retried by the client in the same transaction.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *txnRetryTracker) observe(transactionID []byte, err error) {
+	if len(transactionID) == 0 || !isRetryable(err) {
+		return
+	}
+	t.counts.add(string(transactionID), 1)
+}
+
+// complete returns the retry count of the transaction and stops tracking it.
+func (t *txnRetryTracker) complete(transactionID []byte) int {
+	if len(transactionID) == 0 {
+		return 0
+	}
+	return t.counts.remove(string(transactionID))
+}
+
+// requestTransactionID returns the transaction id which req is executed in, if any.
+func requestTransactionID(req interface{}) []byte {
+	switch req := req.(type) {
+	case *spanner.CommitRequest:
+		return req.GetTransactionId()
+	case *spanner.RollbackRequest:
+		return req.GetTransactionId()
+	case interface {
+		GetTransaction() *spanner.TransactionSelector
+	}:
+		return req.GetTransaction().GetId()
+	default:
+		return nil
+	}
+}
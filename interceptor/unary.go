@@ -0,0 +1,118 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithTransactionSpans makes the unary interceptor create a client span for each transaction lifecycle RPC,
+// i.e. BeginTransaction, Commit and Rollback, named like google.spanner.v1.Spanner/Commit,
+// so transaction boundaries are visible alongside query spans. The created span is decorated instead of the span in the context.
+func WithTransactionSpans() Option {
+	return func(o *interceptorOption) {
+		o.transactionSpans = true
+	}
+}
+
+func isTransactionLifecycleMethod(method string) bool {
+	switch method[strings.LastIndex(method, "/")+1:] {
+	case "BeginTransaction", "Commit", "Rollback":
+		return true
+	default:
+		return false
+	}
+}
+
+// UnaryInterceptor returns a unary client interceptor which decorates the span in the context of unary RPCs,
+// e.g. ExecuteSql, Commit and Rollback.
+func (i *Interceptors) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !i.option.allowMethod(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx = contextWithQueryMode(contextWithMethod(ctx, method), req)
+		var created trace.Span
+		if i.option.createSpan || i.option.transactionSpans && isTransactionLifecycleMethod(method) {
+			ctx, created = i.option.tracer().Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindClient))
+			defer created.End()
+		}
+		i.option.setStaticAttributes(trace.SpanFromContext(ctx))
+		if i.option.dbSemanticConventions {
+			trace.SpanFromContext(ctx).SetAttributes(serverAddressAttribute(cc.Target()))
+		}
+		i.option.tagRequest(ctx, req)
+		i.option.decorateRequest(ctx, i.option.spanFromContext(ctx), req)
+
+		var header, trailer metadata.MD
+		start := i.option.now()
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		sp := i.option.spanFromContext(ctx)
+		i.option.recordError(sp, err)
+		if i.option.rpcSemanticConventions {
+			sp.SetAttributes(rpcAttributes(method)...)
+			sp.SetAttributes(rpcStatusCodeAttribute(err))
+		}
+		if t := i.option.txnRetryTracker; t != nil {
+			switch req.(type) {
+			case *spanner.CommitRequest:
+				if err == nil {
+					sp.SetAttributes(attribute.Int("spanner.txn_retry_attempts", t.complete(requestTransactionID(req))))
+				} else {
+					t.observe(requestTransactionID(req), err)
+				}
+			case *spanner.RollbackRequest:
+				t.complete(requestTransactionID(req))
+			default:
+				t.observe(requestTransactionID(req), err)
+			}
+		}
+		if err != nil {
+			i.option.decorateTrailer(ctx, sp, trailer)
+			return err
+		}
+
+		if rs, ok := reply.(*spanner.ResultSet); ok {
+			if rs.GetMetadata() != nil {
+				i.option.decorateResultSetMetadata(ctx, sp, rs.GetMetadata())
+			}
+			if rs.GetStats() != nil {
+				i.option.decorateStats(ctx, sp, rs.GetStats())
+				if ms, ok := i.option.elapsedTimeMillis(rs.GetStats()); i.option.clientOverhead && ok {
+					i.option.setClientOverhead(sp, start, ms)
+				}
+			}
+			if i.option.bytesReturned {
+				n, ok := parseStatInt(queryStatsField(rs.GetStats(), "bytes_returned"))
+				if !ok {
+					n = int64(proto.Size(rs))
+				}
+				sp.SetAttributes(attribute.Int64("spanner.bytes_returned", n))
+			}
+		}
+		if i.option.operation {
+			transactionType := requestTransactionType(req)
+			var isDML bool
+			switch reply := reply.(type) {
+			case *spanner.ResultSet:
+				isDML = reply.GetStats().GetRowCount() != nil
+				if transactionType == "" {
+					transactionType = metadataTransactionType(reply.GetMetadata())
+				}
+			case *spanner.ExecuteBatchDmlResponse:
+				isDML = true
+			}
+			sp.SetAttributes(attribute.String("spanner.operation", operationName(operationKind(method, isDML), transactionType)))
+		}
+		i.option.decorateHeader(ctx, sp, header)
+		i.option.decorateTrailer(ctx, sp, trailer)
+		i.option.decoratePost(ctx, sp)
+		return nil
+	}
+}
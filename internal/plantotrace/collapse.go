@@ -0,0 +1,80 @@
+package plantotrace
+
+import (
+	"strings"
+
+	"github.com/apstndb/spannerotel/internal/statkey"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithCollapseIdenticalSiblings emits one representative span for sibling subtrees with identical link types,
+// node titles and structure, e.g. a union over repeated identical scans.
+// The representative has collapsed_count, and collapsed.rows and collapsed.latency_ms (in the configured key style) summed over the collapsed siblings.
+func WithCollapseIdenticalSiblings() Option {
+	return func(o *option) {
+		o.collapseSiblings = true
+	}
+}
+
+// subtreeSignature returns a string which is identical for structurally identical subtrees.
+func subtreeSignature(o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode) string {
+	var sb strings.Builder
+	sb.WriteString(nodeTitle(o, planNode))
+	sb.WriteString("(")
+	for i, childLink := range planNode.GetChildLinks() {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(childLink.GetType())
+		sb.WriteString(":")
+		sb.WriteString(subtreeSignature(o, planNodes, childNode(planNodes, childLink)))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// collapseSiblings groups visible children of planNode by subtree signature in the order of child links.
+// It returns the representative child links, and records the collapsed siblings of each representative in o.collapsed.
+func collapseSiblings(o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode) []*spanner.PlanNode_ChildLink {
+	var representatives []*spanner.PlanNode_ChildLink
+	groups := make(map[string]int32)
+	for _, childLink := range planNode.GetChildLinks() {
+		child := childNode(planNodes, childLink)
+		if child == nil {
+			continue
+		}
+		if !isVisible(child) {
+			representatives = append(representatives, childLink)
+			continue
+		}
+		signature := childLink.GetType() + ":" + subtreeSignature(o, planNodes, child)
+		if index, ok := groups[signature]; ok {
+			o.collapsed[index] = append(o.collapsed[index], child)
+			continue
+		}
+		groups[signature] = child.GetIndex()
+		o.collapsed[child.GetIndex()] = []*spanner.PlanNode{child}
+		representatives = append(representatives, childLink)
+	}
+	return representatives
+}
+
+// collapsedAttributes returns attributes of a representative span of siblings, or nil if nothing is collapsed.
+func collapsedAttributes(o *option, siblings []*spanner.PlanNode) []attribute.KeyValue {
+	if len(siblings) < 2 {
+		return nil
+	}
+	var rows int64
+	var latency float64
+	for _, sibling := range siblings {
+		summary := summarizeNode(o, sibling, nil, nil)
+		rows += summary.Rows
+		latency += summary.LatencyMs
+	}
+	return []attribute.KeyValue{
+		attribute.Int("collapsed_count", len(siblings)),
+		attribute.Int64("collapsed.rows", rows),
+		attribute.Float64("collapsed."+string(statkey.Latency.Format(o.keyStyle)), latency),
+	}
+}
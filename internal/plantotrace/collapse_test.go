@@ -0,0 +1,41 @@
+package plantotrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithCollapseIdenticalSiblings(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Union All", "childLinks": [{"childIndex": 1}, {"childIndex": 2}, {"childIndex": 3}, {"childIndex": 4}]},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+	   "executionStats": {"rows": {"total": "1"}, "latency": {"total": "0.5", "unit": "msecs"}}},
+	  {"index": 2, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+	   "executionStats": {"rows": {"total": "2"}, "latency": {"total": "1", "unit": "msecs"}}},
+	  {"index": 3, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"},
+	   "executionStats": {"rows": {"total": "4"}, "latency": {"total": "2", "unit": "msecs"}}},
+	  {"index": 4, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+	   "executionStats": {"rows": {"total": "3"}, "latency": {"total": "1.5", "unit": "msecs"}}}
+	]}}`)
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, append(opts, WithCollapseIdenticalSiblings())...)
+	})
+
+	want := []string{"1: Table Scan (Table: Singers)", "3: Table Scan (Table: Albums)", "0: Union All"}
+	if got := spanNames(spans); !equalStrings(got, want) {
+		t.Fatalf("spans = %q, want %q", got, want)
+	}
+	attrs := spans[0].Attributes()
+	if v, _ := attributeValue(attrs, "collapsed_count"); v.AsInt64() != 3 {
+		t.Errorf("collapsed_count = %v, want 3", v.AsInt64())
+	}
+	if v, _ := attributeValue(attrs, "collapsed.rows"); v.AsInt64() != 6 {
+		t.Errorf("collapsed.rows = %v, want 6", v.AsInt64())
+	}
+	if v, _ := attributeValue(attrs, "collapsed.latency_ms"); v.AsFloat64() != 3 {
+		t.Errorf("collapsed.latency_ms = %v, want 3", v.AsFloat64())
+	}
+	if _, ok := attributeValue(spans[1].Attributes(), "collapsed_count"); ok {
+		t.Errorf("collapsed_count is set on %q, which is not collapsed", spans[1].Name())
+	}
+}
@@ -0,0 +1,58 @@
+package plantotrace
+
+import (
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithCriticalPath sets spanner.critical_path, the indexes of visible nodes on the critical path joined by ">",
+// e.g. "0>3>7", and spanner.critical_path_ms, its total latency, on the root node span.
+//
+// The critical path is the root-to-leaf chain of visible nodes with the greatest sum of latency in execution stats,
+// computed bottom-up by choosing the child subtree with the greatest such sum at each node.
+// Scalar nodes contribute no latency but their relational descendants are considered.
+// Nothing is set if no node has latency, e.g. in PLAN mode.
+func WithCriticalPath() Option {
+	return func(o *option) {
+		o.criticalPath = true
+	}
+}
+
+// criticalPath returns the visible node indexes on the critical path from planNode and its total latency.
+func criticalPath(o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode) ([]int32, float64) {
+	var bestPath []int32
+	var bestLatency float64
+	for _, childLink := range planNode.GetChildLinks() {
+		child := childNode(planNodes, childLink)
+		if child == nil {
+			continue
+		}
+		path, latency := criticalPath(o, planNodes, child)
+		if bestPath == nil || latency > bestLatency {
+			bestPath, bestLatency = path, latency
+		}
+	}
+	if !isVisible(planNode) {
+		return bestPath, bestLatency
+	}
+	latency := summarizeNode(o, planNode, nil, nil).LatencyMs
+	return append([]int32{planNode.GetIndex()}, bestPath...), latency + bestLatency
+}
+
+func criticalPathAttributes(o *option, planNodes []*spanner.PlanNode, root *spanner.PlanNode) []attribute.KeyValue {
+	path, latency := criticalPath(o, planNodes, root)
+	if latency == 0 {
+		return nil
+	}
+	indexes := make([]string, 0, len(path))
+	for _, index := range path {
+		indexes = append(indexes, strconv.Itoa(int(index)))
+	}
+	return []attribute.KeyValue{
+		attribute.String("spanner.critical_path", strings.Join(indexes, ">")),
+		attribute.Float64("spanner.critical_path_ms", latency),
+	}
+}
@@ -0,0 +1,34 @@
+package plantotrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithCriticalPath(t *testing.T) {
+	// Node 1 is the slowest node, but the chain of nodes 2, 3 and 4 is slower in total.
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Union All", "childLinks": [{"childIndex": 1}, {"childIndex": 2}],
+	   "executionStats": {"latency": {"total": "1", "unit": "msecs"}}},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+	   "executionStats": {"latency": {"total": "5", "unit": "msecs"}}},
+	  {"index": 2, "kind": "RELATIONAL", "displayName": "Hash Aggregate", "childLinks": [{"childIndex": 3}, {"childIndex": 5, "type": "Key"}],
+	   "executionStats": {"latency": {"total": "1", "unit": "msecs"}}},
+	  {"index": 3, "kind": "RELATIONAL", "displayName": "Filter", "childLinks": [{"childIndex": 4}],
+	   "executionStats": {"latency": {"total": "2", "unit": "msecs"}}},
+	  {"index": 4, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"},
+	   "executionStats": {"latency": {"total": "3", "unit": "msecs"}}},
+	  {"index": 5, "kind": "SCALAR", "displayName": "Reference", "shortRepresentation": {"description": "$SingerId"}}
+	]}}`)
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, append(opts, WithCriticalPath())...)
+	})
+
+	attrs := rootSpan(spans).Attributes()
+	if v, _ := attributeValue(attrs, "spanner.critical_path"); v.AsString() != "0>2>3>4" {
+		t.Errorf("spanner.critical_path = %q, want %q", v.AsString(), "0>2>3>4")
+	}
+	if v, _ := attributeValue(attrs, "spanner.critical_path_ms"); v.AsFloat64() != 7 {
+		t.Errorf("spanner.critical_path_ms = %v, want 7", v.AsFloat64())
+	}
+}
@@ -0,0 +1,89 @@
+package plantotrace
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// NodeSummary is a structured summary of a visible plan node.
+type NodeSummary struct {
+	Index int
+	// ParentIndex is the index of the parent node, or -1 for the root node.
+	ParentIndex int
+	LinkType    string
+	Title       string
+	// Start and End are the execution timestamps, or zero if they are absent.
+	Start time.Time
+	End   time.Time
+	// LatencyMs is the total latency in milliseconds, or zero if it is absent.
+	LatencyMs float64
+	// Rows is the total number of rows, or zero if it is absent.
+	Rows int64
+}
+
+// PostProcessor is called with the root node span and summaries of visible nodes in the traversal order (pre-order)
+// after all node spans are created, but before the root node span ends.
+type PostProcessor func(ctx context.Context, root trace.Span, summaries []NodeSummary)
+
+// WithPostProcessor sets a PostProcessor to compute aggregated attributes of the whole plan.
+func WithPostProcessor(postProcessor PostProcessor) Option {
+	return func(o *option) {
+		o.postProcessor = postProcessor
+	}
+}
+
+func parentIndexes(planNodes []*spanner.PlanNode) map[int32]int32 {
+	parents := make(map[int32]int32)
+	for _, node := range planNodes {
+		for _, childLink := range node.GetChildLinks() {
+			parents[childLink.GetChildIndex()] = node.GetIndex()
+		}
+	}
+	return parents
+}
+
+func summarizeNode(o *option, planNode *spanner.PlanNode, link *spanner.PlanNode_ChildLink, parents map[int32]int32) NodeSummary {
+	parentIndex := -1
+	if p, ok := parents[planNode.GetIndex()]; ok && link != nil {
+		parentIndex = int(p)
+	}
+	executionStats := planNode.GetExecutionStats().AsMap()
+	latency, _, _ := statTotalMillis(executionStats, "latency")
+	var rowsTotal int64
+	if rows, ok := executionStats["rows"].(map[string]interface{}); ok {
+		if attr, ok := scalarAttribute("rows", rows["total"]); ok && attr.Value.Type() == attribute.INT64 {
+			rowsTotal = attr.Value.AsInt64()
+		}
+	}
+	start, end := executionTimestamps(planNode)
+	return NodeSummary{
+		Index:       int(planNode.GetIndex()),
+		ParentIndex: parentIndex,
+		LinkType:    link.GetType(),
+		Title:       nodeTitle(o, planNode),
+		Start:       start,
+		End:         end,
+		LatencyMs:   latency,
+		Rows:        rowsTotal,
+	}
+}
+
+// PlanHash returns a hash of the structure of planNodes, i.e. node kinds, display names, metadata and child links.
+// Execution stats are ignored, so executions of the same plan have the same hash.
+func PlanHash(planNodes []*spanner.PlanNode) string {
+	h := fnv.New64a()
+	for _, node := range planNodes {
+		fmt.Fprintf(h, "%d;%v;%s;%v;", node.GetIndex(), node.GetKind(), node.GetDisplayName(), node.GetMetadata().AsMap())
+		for _, childLink := range node.GetChildLinks() {
+			fmt.Fprintf(h, "%d:%s:%s,", childLink.GetChildIndex(), childLink.GetType(), childLink.GetVariable())
+		}
+		fmt.Fprintln(h)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,38 @@
+package plantotrace
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// WithTextPlanAttribute sets spanner.text_plan, the visible plan tree rendered as indented lines
+// of "<index>: [<link type>] <title>", on the root node span. It is truncated by WithMaxAttributeValueLen.
+func WithTextPlanAttribute() Option {
+	return func(o *option) {
+		o.textPlan = true
+	}
+}
+
+func textPlan(o *option, planNodes []*spanner.PlanNode, root *spanner.PlanNode) string {
+	var sb strings.Builder
+	writeTextPlan(&sb, o, planNodes, root, nil, 0)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func writeTextPlan(sb *strings.Builder, o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, link *spanner.PlanNode_ChildLink, depth int) {
+	if isVisible(planNode) {
+		var linkLabel string
+		if t := link.GetType(); t != "" {
+			linkLabel = fmt.Sprintf("[%s] ", t)
+		}
+		fmt.Fprintf(sb, "%s%d: %s%s\n", strings.Repeat("  ", depth), planNode.GetIndex(), linkLabel, nodeTitle(o, planNode))
+		depth++
+	}
+	for _, childLink := range planNode.GetChildLinks() {
+		if child := childNode(planNodes, childLink); child != nil {
+			writeTextPlan(sb, o, planNodes, child, childLink, depth)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package plantotrace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithTextPlanAttribute(t *testing.T) {
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, mustStats(t, `{"queryPlan": `+testPlan+`}`), append(opts, WithTextPlanAttribute())...)
+	})
+
+	want := `0: Distributed Union
+  1: Distributed Cross Apply
+    2: [Input] Table Scan (Table: Albums)
+    3: [Map] Filter Scan
+      4: Table Scan (Table: Singers)`
+	if v, _ := attributeValue(rootSpan(spans).Attributes(), "spanner.text_plan"); v.AsString() != want {
+		t.Errorf("spanner.text_plan = %q, want %q", v.AsString(), want)
+	}
+}
+
+func TestWithTextPlanAttributeTruncated(t *testing.T) {
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, mustStats(t, `{"queryPlan": `+testPlan+`}`), append(opts, WithTextPlanAttribute(), WithMaxAttributeValueLen(32))...)
+	})
+
+	if v, _ := attributeValue(rootSpan(spans).Attributes(), "spanner.text_plan"); len(v.AsString()) > 32 {
+		t.Errorf("spanner.text_plan = %q is longer than 32 bytes", v.AsString())
+	}
+}
@@ -1,7 +1,9 @@
 package plantotrace
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -10,33 +12,302 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apstndb/spannerotel/internal/statkey"
+	"github.com/apstndb/spannerotel/internal/version"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 const name = "spannerspan"
 
-func nodeTitle(node *spanner.PlanNode) string {
+func (o *option) tracer() trace.Tracer {
+	tp := o.tracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(name, trace.WithInstrumentationVersion(version.Version), trace.WithSchemaURL(semconv.SchemaURL))
+}
+
+type option struct {
+	hiddenMetadataFields map[string]bool
+	rootAttributes       []attribute.KeyValue
+	executionSummary     bool
+	maxAttributeValueLen int
+	rootSpanSummary      bool
+	waitTime             bool
+	planRootMinimal      bool
+	clock                func() time.Time
+	referencedTables     bool
+	hideLinkLabel        bool
+	planParentSpan       bool
+	strictParsing        bool
+	spanEvents           bool
+	correlatedSubquery   bool
+	skipZeroDuration     bool
+	compactNodeTitles    bool
+	keyStyle             statkey.Style
+	rowEstimates         bool
+	spanStartOptions     []trace.SpanStartOption
+	withoutIndexPrefix   bool
+	postProcessor        PostProcessor
+	conditionSubqueries  bool
+	collapseSiblings     bool
+	criticalPath         bool
+	textPlan             bool
+	tracerProvider       trace.TracerProvider
+
+	// states of a traversal
+	parents   map[int32]int32
+	summaries []NodeSummary
+	// collapsed maps the index of a representative node to its identical siblings including itself.
+	collapsed map[int32][]*spanner.PlanNode
+}
+
+type Option func(*option)
+
+// defaultHiddenMetadataFields are metadata fields which are not rendered as "key: value" in node titles.
+// call_type, iterator_type and scan_type are already rendered in the operator part of titles,
+// and subquery_cluster_node is rarely useful.
+var defaultHiddenMetadataFields = []string{"call_type", "iterator_type", "scan_type", "subquery_cluster_node"}
+
+// WithHiddenMetadataFields hides the given metadata fields from node titles.
+func WithHiddenMetadataFields(fields ...string) Option {
+	return func(o *option) {
+		for _, field := range fields {
+			o.hiddenMetadataFields[field] = true
+		}
+	}
+}
+
+// WithVisibleMetadataFields shows the given metadata fields in node titles even if they are hidden by default.
+func WithVisibleMetadataFields(fields ...string) Option {
+	return func(o *option) {
+		for _, field := range fields {
+			delete(o.hiddenMetadataFields, field)
+		}
+	}
+}
+
+// WithRootAttributes sets the given attributes on the root plan node span.
+func WithRootAttributes(attrs ...attribute.KeyValue) Option {
+	return func(o *option) {
+		o.rootAttributes = append(o.rootAttributes, attrs...)
+	}
+}
+
+// WithExecutionSummaryAttributes sets scalar fields of execution_summary other than timestamps,
+// e.g. num_executions, as execution_summary.* attributes on node spans.
+func WithExecutionSummaryAttributes() Option {
+	return func(o *option) {
+		o.executionSummary = true
+	}
+}
+
+// WithMaxAttributeValueLen truncates span names and string attributes longer than n bytes.
+// n <= 0 means no limit.
+func WithMaxAttributeValueLen(n int) Option {
+	return func(o *option) {
+		o.maxAttributeValueLen = n
+	}
+}
+
+// WithRootSpanSummary appends a short summary of the query, the first scan target in the plan, to the root span name.
+func WithRootSpanSummary() Option {
+	return func(o *option) {
+		o.rootSpanSummary = true
+	}
+}
+
+// WithWaitTime sets latency_ms, cpu_time_ms and wait_time_ms, which is latency minus CPU time clamped at zero,
+// on node spans which have both latency and cpu_time in execution stats.
+func WithWaitTime() Option {
+	return func(o *option) {
+		o.waitTime = true
+	}
+}
+
+// WithPlanRootMinimal makes the root node span carry only structural attributes (index) and its title.
+// Root attributes set by WithRootAttributes are also omitted.
+func WithPlanRootMinimal() Option {
+	return func(o *option) {
+		o.planRootMinimal = true
+	}
+}
+
+// WithReferencedTables sets spanner.tables and spanner.indexes, the sorted distinct scan targets of table scans
+// and index scans, on the root node span.
+// It also sets spanner.index_seeks, the number of scans with a seek condition,
+// and spanner.table_scans, the number of table scans without a seek condition, i.e. full table scans.
+func WithReferencedTables() Option {
+	return func(o *option) {
+		o.referencedTables = true
+	}
+}
+
+// WithLinkLabelInSpanName controls whether the "[link type] " prefix appears in span names. The default is true.
+// If it is false, the link type is set as child_link_type attribute instead.
+func WithLinkLabelInSpanName(enabled bool) Option {
+	return func(o *option) {
+		o.hideLinkLabel = !enabled
+	}
+}
+
+// WithPlanParentSpan creates a synthetic "query plan" span which covers the execution of the root node,
+// and roots all node spans under it.
+func WithPlanParentSpan() Option {
+	return func(o *option) {
+		o.planParentSpan = true
+	}
+}
+
+// WithPlanAsSpanEvents adds one event per visible node to the span in the context instead of creating nested spans.
+// It keeps per-node data with one span for large plans, but loses the nesting and timing hierarchy of nodes.
+// Each event has index, parent_index (-1 for the root node) and child_link_type attributes,
+// so the plan tree can be reconstructed from the flat event list.
+// Options only applicable to node spans are ignored in this mode.
+func WithPlanAsSpanEvents() Option {
+	return func(o *option) {
+		o.spanEvents = true
+	}
+}
+
+// WithCorrelatedSubqueryDetection sets spanner.has_correlated_subquery on the root node span
+// if the plan contains a correlated subquery. See hasCorrelatedSubquery for the heuristic.
+func WithCorrelatedSubqueryDetection() Option {
+	return func(o *option) {
+		o.correlatedSubquery = true
+	}
+}
+
+// WithConditionSubqueries sets <link type>.subqueries attributes alongside condition attributes
+// if the short representation of the condition references scalar subqueries.
+// Each value is "<variable> = <index>: <title>", which resolves a variable in the condition to its subquery node.
+func WithConditionSubqueries() Option {
+	return func(o *option) {
+		o.conditionSubqueries = true
+	}
+}
+
+// WithSkipZeroDurationNodes omits spans of nodes whose execution start and end timestamps are identical.
+// Descendants of omitted nodes are still processed and attached to the nearest emitted ancestor.
+// Nodes without execution timestamps are not omitted.
+func WithSkipZeroDurationNodes() Option {
+	return func(o *option) {
+		o.skipZeroDuration = true
+	}
+}
+
+// WithCompactNodeTitles uses only the operator part of node titles, e.g. "Table Scan", as span names,
+// and sets metadata fields which are not hidden as metadata.* attributes instead.
+func WithCompactNodeTitles() Option {
+	return func(o *option) {
+		o.compactNodeTitles = true
+	}
+}
+
+// WithKeyStyle sets the style of attribute keys of numeric stats with units. The default is statkey.Underscore.
+func WithKeyStyle(style statkey.Style) Option {
+	return func(o *option) {
+		o.keyStyle = style
+	}
+}
+
+// WithRowEstimates sets rows.estimated, rows.actual and rows.estimation_error (actual / estimated)
+// on node spans which have both the estimated rows in metadata and the actual rows in execution stats.
+// See estimatedRowsFields for the probed metadata fields.
+func WithRowEstimates() Option {
+	return func(o *option) {
+		o.rowEstimates = true
+	}
+}
+
+// WithSpanStartOptions adds opts to the options of starting node spans.
+// The start timestamp of node spans is applied after opts, so it can't be overridden.
+func WithSpanStartOptions(opts ...trace.SpanStartOption) Option {
+	return func(o *option) {
+		o.spanStartOptions = append(o.spanStartOptions, opts...)
+	}
+}
+
+// WithoutIndexPrefix drops the zero-padded "<index>: " prefix from span names. The index is still set as an attribute.
+func WithoutIndexPrefix() Option {
+	return func(o *option) {
+		o.withoutIndexPrefix = true
+	}
+}
+
+// WithStrictParsing reports values in execution stats which can't be parsed to the OpenTelemetry error handler
+// instead of silently ignoring them.
+func WithStrictParsing() Option {
+	return func(o *option) {
+		o.strictParsing = true
+	}
+}
+
+func (o *option) handleParseError(err error) {
+	if o.strictParsing {
+		otel.Handle(fmt.Errorf("plantotrace: %w", err))
+	}
+}
+
+// WithTracerProvider creates plan spans by tp instead of the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *option) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithClock sets the clock used for spans of nodes without execution timestamps. The default is time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(o *option) {
+		o.clock = clock
+	}
+}
+
+func newOption(opts ...Option) *option {
+	o := &option{
+		hiddenMetadataFields: make(map[string]bool),
+		clock:                time.Now,
+	}
+	WithHiddenMetadataFields(defaultHiddenMetadataFields...)(o)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func nodeTitle(o *option, node *spanner.PlanNode) string {
+	if o.compactNodeTitles {
+		return nodeOperator(node)
+	}
+	return joinIfNotEmpty(" ", nodeOperator(node), encloseIfNotEmpty("(", strings.Join(nodeFields(o, node), ", "), ")"))
+}
+
+func nodeOperator(node *spanner.PlanNode) string {
 	metadataFields := node.GetMetadata().GetFields()
 
-	operator := joinIfNotEmpty(" ",
+	return joinIfNotEmpty(" ",
 		metadataFields["call_type"].GetStringValue(),
 		metadataFields["iterator_type"].GetStringValue(),
 		strings.TrimSuffix(metadataFields["scan_type"].GetStringValue(), "Scan"),
 		node.GetDisplayName(),
 	)
+}
+
+func nodeFields(o *option, node *spanner.PlanNode) []string {
+	metadataFields := node.GetMetadata().GetFields()
 
 	fields := make([]string, 0)
 	for k, v := range metadataFields {
-		switch k {
-		case "call_type", "iterator_type": // Skip because it is displayed in node title
-			continue
-		case "scan_type": // Skip because it is combined with scan_target
-			continue
-		case "subquery_cluster_node": // Skip because it is useless
+		if o.hiddenMetadataFields[k] {
 			continue
+		}
+		switch k {
 		case "scan_target":
 			fields = append(fields, fmt.Sprintf("%s: %s",
 				strings.TrimSuffix(metadataFields["scan_type"].GetStringValue(), "Scan"),
@@ -47,8 +318,20 @@ func nodeTitle(node *spanner.PlanNode) string {
 	}
 
 	sort.Strings(fields)
+	return fields
+}
 
-	return joinIfNotEmpty(" ", operator, encloseIfNotEmpty("(", strings.Join(fields, ", "), ")"))
+// metadataAttributes returns metadata fields which are not hidden as metadata.* attributes.
+func metadataAttributes(o *option, node *spanner.PlanNode) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for k, v := range node.GetMetadata().GetFields() {
+		if o.hiddenMetadataFields[k] {
+			continue
+		}
+		attrs = append(attrs, attribute.String("metadata."+k, v.GetStringValue()))
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
 }
 
 func joinIfNotEmpty(sep string, input ...string) string {
@@ -68,10 +351,185 @@ func encloseIfNotEmpty(open, input, close string) string {
 	return open + input + close
 }
 
-func Span(ctx context.Context, stats *spanner.ResultSetStats) {
+// Span creates spans for the query plan in stats as descendants of the span in ctx.
+//
+// Node spans are timestamped by execution_start_timestamp and execution_end_timestamp in execution stats,
+// inheriting the parent's timestamps if they are absent.
+// If no node has execution stats, e.g. the query was executed in PLAN mode, spans are timestamped by the clock
+// at creation and end, so the tree structure is preserved but their durations are meaningless.
+func Span(ctx context.Context, stats *spanner.ResultSetStats, opts ...Option) {
 	if stats.GetQueryPlan() != nil {
-		processNode(ctx, stats.GetQueryPlan().GetPlanNodes(), stats.GetQueryPlan().GetPlanNodes()[0], nil, time.Time{}, time.Time{})
+		o := newOption(opts...)
+		planNodes := stats.GetQueryPlan().GetPlanNodes()
+		if len(planNodes) == 0 {
+			// The plan is inconsistent with stats, so there is no root node.
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("spanner.plan_empty", true))
+			return
+		}
+		spanFromNode(ctx, o, planNodes, 0)
+	}
+}
+
+// SpanFromNode is like Span but creates spans for the subtree rooted at planNodes[rootIndex],
+// e.g. to render only a subquery branch. The root node is treated as the root of the plan, so root-only attributes are set on it.
+// It does nothing if rootIndex is out of range.
+// In span events mode, events of visible nodes in the subtree are added.
+func SpanFromNode(ctx context.Context, planNodes []*spanner.PlanNode, rootIndex int, opts ...Option) {
+	if rootIndex < 0 || rootIndex >= len(planNodes) {
+		return
+	}
+	spanFromNode(ctx, newOption(opts...), planNodes, rootIndex)
+}
+
+func spanFromNode(ctx context.Context, o *option, planNodes []*spanner.PlanNode, rootIndex int) {
+	if o.spanEvents {
+		addNodeEvents(trace.SpanFromContext(ctx), o, planNodes, rootIndex)
+		return
+	}
+	root := planNodes[rootIndex]
+	if o.planParentSpan {
+		var span trace.Span
+		start, end := executionTimestamps(root)
+		if start.IsZero() {
+			start = o.clock()
+		}
+		ctx, span = o.tracer().Start(ctx, "query plan", trace.WithTimestamp(start))
+		defer func() {
+			if end.IsZero() {
+				end = o.clock()
+			}
+			span.End(trace.WithTimestamp(end))
+		}()
+	}
+	if o.postProcessor != nil {
+		o.parents = parentIndexes(planNodes)
+	}
+	if o.collapseSiblings {
+		o.collapsed = make(map[int32][]*spanner.PlanNode)
+	}
+	processNode(ctx, o, planNodes, root, nil, time.Time{}, time.Time{})
+}
+
+// addNodeEvents adds events of visible nodes in the subtree rooted at planNodes[rootIndex] to span in the order of index.
+// The subtree root has no parent_index and child_link_type because it is treated as the root of the plan.
+func addNodeEvents(span trace.Span, o *option, planNodes []*spanner.PlanNode, rootIndex int) {
+	subtree := subtreeIndexes(planNodes, rootIndex)
+	linkTypes := make(map[int32]string)
+	for _, node := range planNodes {
+		if !subtree[node.GetIndex()] {
+			continue
+		}
+		for _, childLink := range node.GetChildLinks() {
+			linkTypes[childLink.GetChildIndex()] = childLink.GetType()
+		}
+	}
+	parents := parentIndexes(planNodes)
+
+	for _, node := range planNodes {
+		if !isVisible(node) || !subtree[node.GetIndex()] {
+			continue
+		}
+		// parent_index refers to the nearest visible ancestor because scalar nodes have no events.
+		parentIndex := -1
+		for p, ok := parents[node.GetIndex()]; ok && subtree[p]; p, ok = parents[p] {
+			if p >= 0 && int(p) < len(planNodes) && isVisible(planNodes[p]) {
+				parentIndex = int(p)
+				break
+			}
+		}
+		attrs := []attribute.KeyValue{attribute.Int("index", int(node.GetIndex())), attribute.Int("parent_index", parentIndex)}
+		if t := linkTypes[node.GetIndex()]; t != "" {
+			attrs = append(attrs, attribute.String("child_link_type", t))
+		}
+		executionStats := node.GetExecutionStats().AsMap()
+		if rows, ok := executionStats["rows"].(map[string]interface{}); ok {
+			if total, ok := scalarAttribute("rows", rows["total"]); ok {
+				attrs = append(attrs, total)
+			}
+		}
+		if latency, ok, _ := statTotalMillis(executionStats, "latency"); ok {
+			attrs = append(attrs, statkey.Latency.Format(o.keyStyle).Float64(latency))
+		}
+
+		eventOptions := []trace.EventOption{trace.WithAttributes(attrs...)}
+		if start, _ := executionTimestamps(node); !start.IsZero() {
+			eventOptions = append(eventOptions, trace.WithTimestamp(start))
+		}
+		title, _ := TruncateString(nodeTitle(o, node), o.maxAttributeValueLen)
+		span.AddEvent(title, eventOptions...)
+	}
+}
+
+// subtreeIndexes returns the set of indexes of planNodes[rootIndex] and its descendants.
+func subtreeIndexes(planNodes []*spanner.PlanNode, rootIndex int) map[int32]bool {
+	subtree := make(map[int32]bool)
+	stack := []*spanner.PlanNode{planNodes[rootIndex]}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if subtree[node.GetIndex()] {
+			continue
+		}
+		subtree[node.GetIndex()] = true
+		for _, childLink := range node.GetChildLinks() {
+			if child := childNode(planNodes, childLink); child != nil {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return subtree
+}
+
+// executionTimestamps returns the execution start and end timestamps of planNode, or zero times if they are absent.
+func executionTimestamps(planNode *spanner.PlanNode) (start, end time.Time) {
+	executionSummary, _ := planNode.GetExecutionStats().AsMap()["execution_summary"].(map[string]interface{})
+	sStart, _ := executionSummary["execution_start_timestamp"].(string)
+	if t, err := parseUnixWithFraction(sStart); err == nil && isExecutionTimestamp(t) {
+		start = t
+	}
+	sEnd, _ := executionSummary["execution_end_timestamp"].(string)
+	if t, err := parseUnixWithFraction(sEnd); err == nil && isExecutionTimestamp(t) {
+		end = t
+	}
+	return start, end
+}
+
+// isExecutionTimestamp reports whether t is a plausible execution timestamp.
+// Timestamps at or before the Unix epoch are treated as absent so that they never produce epoch-timestamped spans.
+func isExecutionTimestamp(t time.Time) bool {
+	return t.Unix() > 0
+}
+
+// childNode returns the plan node referenced by childLink, or nil if the index is out of range.
+func childNode(planNodes []*spanner.PlanNode, childLink *spanner.PlanNode_ChildLink) *spanner.PlanNode {
+	i := int(childLink.GetChildIndex())
+	if i < 0 || i >= len(planNodes) {
+		return nil
+	}
+	return planNodes[i]
+}
+
+// SpanCount returns the number of spans which Span creates for stats at most.
+func SpanCount(stats *spanner.ResultSetStats, opts ...Option) int {
+	o := newOption(opts...)
+	if stats.GetQueryPlan() == nil || o.spanEvents {
+		return 0
+	}
+	var n int
+	if o.planParentSpan {
+		n++
 	}
+	for _, node := range stats.GetQueryPlan().GetPlanNodes() {
+		if isVisible(node) {
+			n++
+		}
+	}
+	return n
+}
+
+// SpanWithParent is like Span but roots the plan spans under parent instead of the span in ctx.
+func SpanWithParent(ctx context.Context, parent trace.Span, stats *spanner.ResultSetStats, opts ...Option) {
+	Span(trace.ContextWithSpan(ctx, parent), stats, opts...)
 }
 
 func maxVisible(planNodes []*spanner.PlanNode) int {
@@ -83,53 +541,404 @@ func maxVisible(planNodes []*spanner.PlanNode) int {
 	return 0
 }
 
-func processNode(ctx context.Context, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, link *spanner.PlanNode_ChildLink, parentStart, parentEnd time.Time) {
+func processNode(ctx context.Context, o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, link *spanner.PlanNode_ChildLink, parentStart, parentEnd time.Time) {
 	executionSummary, ok := planNode.GetExecutionStats().AsMap()["execution_summary"].(map[string]interface{})
 	if ok {
 		sStart, _ := executionSummary["execution_start_timestamp"].(string)
-		executionStartTimestamp, _ := parseUnixWithFraction(sStart)
-		if !executionStartTimestamp.IsZero() {
+		executionStartTimestamp, err := parseUnixWithFraction(sStart)
+		if err != nil && sStart != "" {
+			o.handleParseError(fmt.Errorf("invalid execution_start_timestamp %q: %w", sStart, err))
+		}
+		if isExecutionTimestamp(executionStartTimestamp) {
 			parentStart = executionStartTimestamp
 		}
 		sEnd, _ := executionSummary["execution_end_timestamp"].(string)
-		executionEndTimestamp, _ := parseUnixWithFraction(sEnd)
-		if !executionEndTimestamp.IsZero() {
+		executionEndTimestamp, err := parseUnixWithFraction(sEnd)
+		if err != nil && sEnd != "" {
+			o.handleParseError(fmt.Errorf("invalid execution_end_timestamp %q: %w", sEnd, err))
+		}
+		if isExecutionTimestamp(executionEndTimestamp) {
 			parentEnd = executionEndTimestamp
 		}
 
 		if os.Getenv("DEBUG") != "" {
-			b, _ := planNode.GetExecutionStats().MarshalJSON()
-			fmt.Println(planNode.Index, executionStartTimestamp, executionEndTimestamp, string(b))
+			fmt.Println(planNode.GetIndex(), nodeTitle(o, planNode), executionStartTimestamp, executionEndTimestamp, marshalJSON(planNode.GetExecutionStats()))
 		}
 	}
 
 	if isVisible(planNode) {
+		if o.postProcessor != nil {
+			o.summaries = append(o.summaries, summarizeNode(o, planNode, link, o.parents))
+		}
+		if o.skipZeroDuration && !parentStart.IsZero() && parentStart.Equal(parentEnd) {
+			processChildren(ctx, o, planNodes, planNode, parentStart, parentEnd)
+			return
+		}
+
 		var span trace.Span
 		var linkLabel string
-		if t := link.GetType(); t != "" {
+		if t := link.GetType(); t != "" && !o.hideLinkLabel {
 			linkLabel = fmt.Sprintf("[%s] ", t)
 		}
-		ctx, span = otel.Tracer(name).Start(ctx, fmt.Sprintf("%0*d: %s%s", len(fmt.Sprint(maxVisible(planNodes))), planNode.GetIndex(), linkLabel, nodeTitle(planNode)), trace.WithTimestamp(parentStart))
-		defer span.End(trace.WithTimestamp(parentEnd))
+		spanName := linkLabel + nodeTitle(o, planNode)
+		if !o.withoutIndexPrefix {
+			spanName = fmt.Sprintf("%0*d: %s", len(fmt.Sprint(maxVisible(planNodes))), planNode.GetIndex(), spanName)
+		}
+		if link == nil && o.rootSpanSummary {
+			if summary := rootSummary(planNodes); summary != "" {
+				spanName = fmt.Sprintf("%s on %s", spanName, summary)
+			}
+		}
+		spanName, nameTruncated := TruncateString(spanName, o.maxAttributeValueLen)
+		start := parentStart
+		if start.IsZero() {
+			start = o.clock()
+		}
+		startOptions := append(append([]trace.SpanStartOption{}, o.spanStartOptions...), trace.WithTimestamp(start))
+		ctx, span = o.tracer().Start(ctx, spanName, startOptions...)
+		defer func(span trace.Span) {
+			end := parentEnd
+			if end.IsZero() {
+				end = o.clock()
+			}
+			span.End(trace.WithTimestamp(end))
+		}(span)
+
+		span = TruncatingSpan(span, o.maxAttributeValueLen)
+		if nameTruncated {
+			span.SetAttributes(attribute.Bool("name.truncated", true))
+		}
 
 		span.SetAttributes(attribute.Int("index", int(planNode.GetIndex())))
-		for _, childLink := range planNode.GetChildLinks() {
-			childNode := planNodes[childLink.GetChildIndex()]
-			if childNode.GetDisplayName() == "Function" && (strings.HasSuffix(childLink.GetType(), "Condition") || childLink.GetType() == "Split Range") {
-				span.SetAttributes(attribute.String(childLink.GetType(), childNode.GetShortRepresentation().GetDescription()))
+		if t := link.GetType(); t != "" && o.hideLinkLabel {
+			span.SetAttributes(attribute.String("child_link_type", t))
+		}
+		if v := link.GetVariable(); v != "" {
+			span.SetAttributes(attribute.String("child_link_variable", v))
+		}
+		if link != nil || !o.planRootMinimal {
+			span.SetAttributes(descriptiveAttributes(o, planNodes, planNode, link, executionSummary)...)
+		}
+		if o.collapseSiblings {
+			span.SetAttributes(collapsedAttributes(o, o.collapsed[planNode.GetIndex()])...)
+		}
+
+		processChildren(ctx, o, planNodes, planNode, parentStart, parentEnd)
+
+		if link == nil && o.textPlan {
+			span.SetAttributes(attribute.String("spanner.text_plan", textPlan(o, planNodes, planNode)))
+		}
+		if link == nil && o.criticalPath {
+			span.SetAttributes(criticalPathAttributes(o, planNodes, planNode)...)
+		}
+		// span is ended by defer after the post processor.
+		if link == nil && o.postProcessor != nil {
+			o.postProcessor(ctx, span, o.summaries)
+		}
+	}
+}
+
+func processChildren(ctx context.Context, o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, parentStart, parentEnd time.Time) {
+	childLinks := planNode.GetChildLinks()
+	if o.collapseSiblings {
+		childLinks = collapseSiblings(o, planNodes, planNode)
+	}
+	for _, childLink := range childLinks {
+		child := childNode(planNodes, childLink)
+		if child == nil {
+			continue
+		}
+		processNode(ctx, o, planNodes, child, childLink, parentStart, parentEnd)
+	}
+}
+
+// descriptiveAttributes returns node span attributes other than structural ones.
+func descriptiveAttributes(o *option, planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, link *spanner.PlanNode_ChildLink, executionSummary map[string]interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if o.compactNodeTitles {
+		attrs = append(attrs, metadataAttributes(o, planNode)...)
+	}
+	if o.waitTime {
+		attrs = append(attrs, waitTimeAttributes(o, planNode)...)
+	}
+	if o.rowEstimates {
+		attrs = append(attrs, rowEstimatesAttributes(planNode)...)
+	}
+	if o.executionSummary {
+		attrs = append(attrs, executionSummaryAttributes(executionSummary)...)
+	}
+	if link == nil {
+		attrs = append(attrs, o.rootAttributes...)
+		if o.referencedTables {
+			attrs = append(attrs, referencedTablesAttributes(planNodes)...)
+		}
+		if o.correlatedSubquery && hasCorrelatedSubquery(planNodes, planNode, false) {
+			attrs = append(attrs, attribute.Bool("spanner.has_correlated_subquery", true))
+		}
+	}
+	for _, childLink := range planNode.GetChildLinks() {
+		child := childNode(planNodes, childLink)
+		if child.GetDisplayName() == "Function" && (strings.HasSuffix(childLink.GetType(), "Condition") || childLink.GetType() == "Split Range") {
+			attrs = append(attrs, attribute.String(childLink.GetType(), child.GetShortRepresentation().GetDescription()))
+			if o.conditionSubqueries {
+				if subqueries := conditionSubqueries(o, planNodes, child.GetShortRepresentation()); len(subqueries) > 0 {
+					attrs = append(attrs, attribute.StringSlice(childLink.GetType()+".subqueries", subqueries))
+				}
+			}
+		}
+	}
+	return attrs
+}
+
+// conditionSubqueries resolves the subquery variables referenced by shortRepresentation to node titles, sorted by variable.
+func conditionSubqueries(o *option, planNodes []*spanner.PlanNode, shortRepresentation *spanner.PlanNode_ShortRepresentation) []string {
+	subqueries := shortRepresentation.GetSubqueries()
+	variables := make([]string, 0, len(subqueries))
+	for variable := range subqueries {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+
+	var result []string
+	for _, variable := range variables {
+		index := subqueries[variable]
+		var title string
+		if index >= 0 && int(index) < len(planNodes) {
+			title = nodeTitle(o, planNodes[index])
+		}
+		result = append(result, fmt.Sprintf("%s = %d: %s", variable, index, title))
+	}
+	return result
+}
+
+// estimatedRowsFields are metadata fields which may contain the optimizer's estimated rows, probed in this order.
+// The actual rows are read from rows.total in execution stats, which is only available in PROFILE mode.
+var estimatedRowsFields = []string{"estimated_rows", "estimated_row_count"}
+
+func rowEstimatesAttributes(planNode *spanner.PlanNode) []attribute.KeyValue {
+	metadataFields := planNode.GetMetadata().GetFields()
+	var estimated int64
+	var hasEstimated bool
+	for _, field := range estimatedRowsFields {
+		if attr, ok := scalarAttribute(field, metadataFields[field].AsInterface()); ok && attr.Value.Type() == attribute.INT64 {
+			estimated, hasEstimated = attr.Value.AsInt64(), true
+			break
+		}
+	}
+	if !hasEstimated {
+		return nil
+	}
+
+	rows, _ := planNode.GetExecutionStats().AsMap()["rows"].(map[string]interface{})
+	actualAttr, ok := scalarAttribute("rows.actual", rows["total"])
+	if !ok || actualAttr.Value.Type() != attribute.INT64 {
+		return nil
+	}
+	actual := actualAttr.Value.AsInt64()
+
+	attrs := []attribute.KeyValue{
+		attribute.Int64("rows.estimated", estimated),
+		attribute.Int64("rows.actual", actual),
+	}
+	if estimated != 0 {
+		attrs = append(attrs, attribute.Float64("rows.estimation_error", float64(actual)/float64(estimated)))
+	}
+	return attrs
+}
+
+func waitTimeAttributes(o *option, planNode *spanner.PlanNode) []attribute.KeyValue {
+	executionStats := planNode.GetExecutionStats().AsMap()
+	latency, ok, err := statTotalMillis(executionStats, "latency")
+	if err != nil {
+		o.handleParseError(err)
+	}
+	if !ok {
+		return nil
+	}
+	cpuTime, ok, err := statTotalMillis(executionStats, "cpu_time")
+	if err != nil {
+		o.handleParseError(err)
+	}
+	if !ok {
+		return nil
+	}
+	waitTime := latency - cpuTime
+	if waitTime < 0 {
+		waitTime = 0
+	}
+	return []attribute.KeyValue{
+		statkey.Latency.Format(o.keyStyle).Float64(latency),
+		statkey.CPUTime.Format(o.keyStyle).Float64(cpuTime),
+		statkey.WaitTime.Format(o.keyStyle).Float64(waitTime),
+	}
+}
+
+// statTotalMillis returns the total of the execution stat like {"total": "1.23", "unit": "msecs"} in milliseconds.
+// ok is false if the stat is absent or invalid, and err is non-nil if the stat is invalid.
+func statTotalMillis(executionStats map[string]interface{}, key string) (total float64, ok bool, err error) {
+	stat, ok := executionStats[key].(map[string]interface{})
+	if !ok {
+		return 0, false, nil
+	}
+	totalStr, _ := stat["total"].(string)
+	total, err = strconv.ParseFloat(totalStr, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s.total %q: %w", key, totalStr, err)
+	}
+	unit, _ := stat["unit"].(string)
+	switch unit {
+	case "msecs", "":
+		return total, true, nil
+	case "usecs":
+		return total / 1000, true, nil
+	case "secs":
+		return total * 1000, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown %s.unit %q", key, unit)
+	}
+}
+
+func executionSummaryAttributes(executionSummary map[string]interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for k, v := range executionSummary {
+		switch k {
+		case "execution_start_timestamp", "execution_end_timestamp": // Skip because they are span timestamps
+			continue
+		}
+		if attr, ok := scalarAttribute("execution_summary."+k, v); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
+
+// scalarAttribute converts a scalar value of structpb.Struct.AsMap() to an attribute.
+// Numbers in strings are converted to numeric attributes.
+func scalarAttribute(key string, v interface{}) (attribute.KeyValue, bool) {
+	switch v := v.(type) {
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return attribute.Int64(key, n), true
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return attribute.Float64(key, f), true
+		}
+		return attribute.String(key, v), true
+	case float64:
+		if v == float64(int64(v)) {
+			return attribute.Int64(key, int64(v)), true
+		}
+		return attribute.Float64(key, v), true
+	case bool:
+		return attribute.Bool(key, v), true
+	default:
+		return attribute.KeyValue{}, false
+	}
+}
+
+func referencedTablesAttributes(planNodes []*spanner.PlanNode) []attribute.KeyValue {
+	tables := make(map[string]bool)
+	indexes := make(map[string]bool)
+	var seeks, tableScans int
+	for _, node := range planNodes {
+		fields := node.GetMetadata().GetFields()
+		target := fields["scan_target"].GetStringValue()
+		if target == "" {
+			continue
+		}
+		seek := hasSeekCondition(node)
+		if seek {
+			seeks++
+		}
+		switch fields["scan_type"].GetStringValue() {
+		case "TableScan":
+			tables[target] = true
+			if !seek {
+				tableScans++
 			}
+		case "IndexScan":
+			indexes[target] = true
+		}
+	}
+	attrs := []attribute.KeyValue{
+		attribute.Int("spanner.index_seeks", seeks),
+		attribute.Int("spanner.table_scans", tableScans),
+	}
+	if len(tables) > 0 {
+		attrs = append(attrs, attribute.StringSlice("spanner.tables", sortedKeys(tables)))
+	}
+	if len(indexes) > 0 {
+		attrs = append(attrs, attribute.StringSlice("spanner.indexes", sortedKeys(indexes)))
+	}
+	return attrs
+}
+
+// hasSeekCondition reports whether the scan node reads only key ranges by a seek condition.
+func hasSeekCondition(planNode *spanner.PlanNode) bool {
+	for _, childLink := range planNode.GetChildLinks() {
+		if childLink.GetType() == "Seek Condition" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCorrelatedSubquery detects a correlated subquery under planNode.
+// Spanner executes correlated subqueries by Apply operators (e.g. Cross Apply), which re-evaluate
+// the child linked as "Map" for each row of the "Input" child. So a node whose display name ends with "Subquery"
+// in the Map side of an Apply is considered a correlated subquery.
+func hasCorrelatedSubquery(planNodes []*spanner.PlanNode, planNode *spanner.PlanNode, inMap bool) bool {
+	if inMap && strings.HasSuffix(planNode.GetDisplayName(), "Subquery") {
+		return true
+	}
+	for _, childLink := range planNode.GetChildLinks() {
+		if hasCorrelatedSubquery(planNodes, childNode(planNodes, childLink), inMap || childLink.GetType() == "Map") {
+			return true
 		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-		for _, childLink := range planNode.GetChildLinks() {
-			processNode(ctx, planNodes, planNodes[childLink.GetChildIndex()], childLink, parentStart, parentEnd)
+// rootSummary returns the first scan target in the plan.
+func rootSummary(planNodes []*spanner.PlanNode) string {
+	for _, node := range planNodes {
+		if target := node.GetMetadata().GetFields()["scan_target"].GetStringValue(); target != "" {
+			return target
 		}
 	}
+	return ""
 }
 
 func isVisible(planNode *spanner.PlanNode) bool {
 	return planNode.GetKind() == spanner.PlanNode_RELATIONAL || strings.HasSuffix(planNode.GetDisplayName(), "Subquery")
 }
 
+// marshalJSON marshals m by protojson in the compact form.
+// protojson randomizes whitespaces in its output, so it is compacted to make the output stable.
+func marshalJSON(m proto.Message) string {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return fmt.Sprintf("<error: %v>", err)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return string(b)
+	}
+	return buf.String()
+}
+
+// parseUnixWithFraction parses a Unix timestamp with a fraction of seconds like "1637138745.123456".
+// Both parts must consist only of ASCII digits, so signs, spaces and exponents are rejected.
 func parseUnixWithFraction(s string) (time.Time, error) {
 	ss := strings.SplitN(s, ".", 2)
 	if len(ss) != 2 {
@@ -137,8 +946,14 @@ func parseUnixWithFraction(s string) (time.Time, error) {
 	}
 
 	secStr, fracStr := ss[0], ss[1]
+	if !isDigits(secStr) {
+		return time.Time{}, fmt.Errorf("seconds part should be digits, actual: %q", secStr)
+	}
+	if fracStr != "" && !isDigits(fracStr) {
+		return time.Time{}, fmt.Errorf("fraction part should be digits, actual: %q", fracStr)
+	}
 
-	sec, err := strconv.Atoi(secStr)
+	sec, err := strconv.ParseInt(secStr, 10, 64)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -151,5 +966,17 @@ func parseUnixWithFraction(s string) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
-	return time.Unix(int64(sec), int64(nsec)), nil
+	return time.Unix(sec, int64(nsec)), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,37 @@
+//go:build go1.18
+
+package plantotrace
+
+import (
+	"fmt"
+	"testing"
+)
+
+func FuzzParseUnixWithFraction(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"1600000000.123456",
+		"1600000000.",
+		"1600000000.1234567890",
+		"abc.123",
+		"-1.5",
+		"1.2.3",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := parseUnixWithFraction(s)
+		if err != nil {
+			return
+		}
+		// A parsed timestamp is formatted back with the full precision and must be parsed to the same time.
+		formatted := fmt.Sprintf("%d.%09d", got.Unix(), got.Nanosecond())
+		reparsed, err := parseUnixWithFraction(formatted)
+		if err != nil {
+			t.Fatalf("parseUnixWithFraction(%q) = %v, but its formatted %q is rejected: %v", s, got, formatted, err)
+		}
+		if !reparsed.Equal(got) {
+			t.Errorf("parseUnixWithFraction(%q) = %v, but its formatted %q is parsed to %v", s, got, formatted, reparsed)
+		}
+	})
+}
@@ -0,0 +1,613 @@
+package plantotrace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// testPlan is a plan of a query like SELECT * FROM Singers WHERE SingerId IN (SELECT SingerId FROM Albums).
+const testPlan = `{"planNodes": [
+  {"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union",
+   "childLinks": [{"childIndex": 1}, {"childIndex": 5, "type": "Split Range"}],
+   "executionStats": {"rows": {"total": "3"}, "latency": {"total": "2", "unit": "msecs"},
+     "execution_summary": {"execution_start_timestamp": "1600000000.000000", "execution_end_timestamp": "1600000000.002000"}}},
+  {"index": 1, "kind": "RELATIONAL", "displayName": "Distributed Cross Apply",
+   "childLinks": [{"childIndex": 2, "type": "Input"}, {"childIndex": 3, "type": "Map"}],
+   "executionStats": {"rows": {"total": "3"}, "latency": {"total": "1.5", "unit": "msecs"}}},
+  {"index": 2, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"},
+   "executionStats": {"rows": {"total": "5"}, "latency": {"total": "0.5", "unit": "msecs"}}},
+  {"index": 3, "kind": "RELATIONAL", "displayName": "Filter Scan",
+   "childLinks": [{"childIndex": 4}, {"childIndex": 6, "type": "Seek Condition"}],
+   "executionStats": {"rows": {"total": "3"}, "latency": {"total": "0.7", "unit": "msecs"}}},
+  {"index": 4, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+   "executionStats": {"rows": {"total": "3"}, "latency": {"total": "0.6", "unit": "msecs"}}},
+  {"index": 5, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "true"}},
+  {"index": 6, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "($SingerId = $SingerId_1)"}}
+]}`
+
+func mustPlanNodes(t testing.TB, s string) []*spanner.PlanNode {
+	t.Helper()
+	var plan spanner.QueryPlan
+	if err := protojson.Unmarshal([]byte(s), &plan); err != nil {
+		t.Fatalf("invalid plan: %v", err)
+	}
+	return plan.GetPlanNodes()
+}
+
+func mustStats(t testing.TB, s string) *spanner.ResultSetStats {
+	t.Helper()
+	var stats spanner.ResultSetStats
+	if err := protojson.Unmarshal([]byte(s), &stats); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+	return &stats
+}
+
+// recordSpans calls f with a context having a recording parent span, and returns spans ended by f and the parent.
+func recordSpans(t testing.TB, f func(ctx context.Context, opts ...Option)) (spans []sdktrace.ReadOnlySpan, parent sdktrace.ReadOnlySpan) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, sp := tp.Tracer("test").Start(context.Background(), "parent")
+	f(ctx, WithTracerProvider(tp))
+	sp.End()
+	ended := recorder.Ended()
+	return ended[:len(ended)-1], ended[len(ended)-1]
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+func findSpan(t testing.TB, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not found in %q", name, spanNames(spans))
+	return nil
+}
+
+func attributeValue(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSpanFromNodeEvents(t *testing.T) {
+	planNodes := mustPlanNodes(t, testPlan)
+	_, parent := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		SpanFromNode(ctx, planNodes, 3, append(opts, WithPlanAsSpanEvents())...)
+	})
+
+	events := parent.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(events), events)
+	}
+	for i, want := range []struct {
+		index, parentIndex int64
+		childLinkType      string
+	}{
+		{3, -1, ""},
+		{4, 3, ""},
+	} {
+		attrs := events[i].Attributes
+		if v, _ := attributeValue(attrs, "index"); v.AsInt64() != want.index {
+			t.Errorf("events[%d] index = %v, want %v", i, v.AsInt64(), want.index)
+		}
+		if v, _ := attributeValue(attrs, "parent_index"); v.AsInt64() != want.parentIndex {
+			t.Errorf("events[%d] parent_index = %v, want %v", i, v.AsInt64(), want.parentIndex)
+		}
+		if v, _ := attributeValue(attrs, "child_link_type"); v.AsString() != want.childLinkType {
+			t.Errorf("events[%d] child_link_type = %q, want %q", i, v.AsString(), want.childLinkType)
+		}
+	}
+}
+
+func TestWaitTimeAttributes(t *testing.T) {
+	for _, tt := range []struct {
+		desc           string
+		executionStats string
+		want           map[string]float64
+	}{
+		{
+			"latency minus cpu time",
+			`{"latency": {"total": "5", "unit": "msecs"}, "cpu_time": {"total": "1.5", "unit": "msecs"}}`,
+			map[string]float64{"latency_ms": 5, "cpu_time_ms": 1.5, "wait_time_ms": 3.5},
+		},
+		{
+			"different units",
+			`{"latency": {"total": "2", "unit": "msecs"}, "cpu_time": {"total": "500", "unit": "usecs"}}`,
+			map[string]float64{"latency_ms": 2, "cpu_time_ms": 0.5, "wait_time_ms": 1.5},
+		},
+		{
+			"clamped at zero",
+			`{"latency": {"total": "1", "unit": "msecs"}, "cpu_time": {"total": "1.2", "unit": "msecs"}}`,
+			map[string]float64{"latency_ms": 1, "cpu_time_ms": 1.2, "wait_time_ms": 0},
+		},
+		{
+			"without cpu time",
+			`{"latency": {"total": "1", "unit": "msecs"}}`,
+			nil,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			planNodes := mustPlanNodes(t, `{"planNodes": [{"index": 0, "kind": "RELATIONAL", "displayName": "Scan", "executionStats": `+tt.executionStats+`}]}`)
+			attrs := waitTimeAttributes(newOption(), planNodes[0])
+			if len(attrs) != len(tt.want) {
+				t.Fatalf("waitTimeAttributes() = %v, want %v", attrs, tt.want)
+			}
+			for key, want := range tt.want {
+				if v, _ := attributeValue(attrs, key); v.AsFloat64() != want {
+					t.Errorf("%s = %v, want %v", key, v.AsFloat64(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithLinkLabelInSpanName(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": `+testPlan+`}`)
+	for _, tt := range []struct {
+		enabled           bool
+		wantName          string
+		wantChildLinkType bool
+	}{
+		{true, "2: [Input] Table Scan (Table: Albums)", false},
+		{false, "2: Table Scan (Table: Albums)", true},
+	} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			Span(ctx, stats, append(opts, WithLinkLabelInSpanName(tt.enabled))...)
+		})
+		span := findSpan(t, spans, tt.wantName)
+		v, ok := attributeValue(span.Attributes(), "child_link_type")
+		if ok != tt.wantChildLinkType || ok && v.AsString() != "Input" {
+			t.Errorf("WithLinkLabelInSpanName(%v): child_link_type = %q (set: %v), want set: %v", tt.enabled, v.AsString(), ok, tt.wantChildLinkType)
+		}
+	}
+}
+
+func TestWithStrictParsing(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Scan",
+	   "executionStats": {"latency": {"total": "1,5", "unit": "msecs"}, "cpu_time": {"total": "1", "unit": "msecs"},
+	     "execution_summary": {"execution_start_timestamp": "yesterday", "execution_end_timestamp": "1600000000.002000"}}}
+	]}}`)
+	for _, tt := range []struct {
+		strict     bool
+		wantErrors int
+	}{
+		{false, 0},
+		{true, 2},
+	} {
+		var errs []error
+		prev := otel.GetErrorHandler()
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { errs = append(errs, err) }))
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			opts = append(opts, WithWaitTime())
+			if tt.strict {
+				opts = append(opts, WithStrictParsing())
+			}
+			Span(ctx, stats, opts...)
+		})
+		otel.SetErrorHandler(prev)
+
+		if len(spans) != 1 {
+			t.Errorf("strict: %v, spans = %q, want 1 span", tt.strict, spanNames(spans))
+		}
+		if len(errs) != tt.wantErrors {
+			t.Errorf("strict: %v, errors = %v, want %d errors", tt.strict, errs, tt.wantErrors)
+		}
+	}
+}
+
+// rootSpan returns the root node span, which ends last.
+func rootSpan(spans []sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	return spans[len(spans)-1]
+}
+
+// correlatedSubqueryPlan is a plan of a query like SELECT ARRAY(SELECT AlbumTitle FROM Albums a WHERE a.SingerId = s.SingerId) FROM Singers s.
+const correlatedSubqueryPlan = `{"planNodes": [
+  {"index": 0, "kind": "RELATIONAL", "displayName": "Cross Apply",
+   "childLinks": [{"childIndex": 1, "type": "Input"}, {"childIndex": 2, "type": "Map"}]},
+  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}},
+  {"index": 2, "kind": "SCALAR", "displayName": "Array Subquery", "childLinks": [{"childIndex": 3}]},
+  {"index": 3, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"}}
+]}`
+
+func TestWithCorrelatedSubqueryDetection(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		plan string
+		want bool
+	}{
+		{"correlated subquery", correlatedSubqueryPlan, true},
+		{"semi join", testPlan, false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+				Span(ctx, mustStats(t, `{"queryPlan": `+tt.plan+`}`), append(opts, WithCorrelatedSubqueryDetection())...)
+			})
+			v, ok := attributeValue(rootSpan(spans).Attributes(), "spanner.has_correlated_subquery")
+			if ok != tt.want || ok && !v.AsBool() {
+				t.Errorf("spanner.has_correlated_subquery = %v (set: %v), want set: %v", v.AsBool(), ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCompactNodeTitles(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Scan",
+	   "metadata": {"scan_type": "IndexScan", "scan_target": "SingersByName", "Full scan": "true", "seekable_key_size": "0"}}
+	]}}`)
+	for _, tt := range []struct {
+		compact   bool
+		wantName  string
+		wantAttrs map[string]string
+	}{
+		{false, "0: Index Scan (Full scan: true, Index: SingersByName, seekable_key_size: 0)", nil},
+		{true, "0: Index Scan", map[string]string{
+			"metadata.Full scan":         "true",
+			"metadata.scan_target":       "SingersByName",
+			"metadata.seekable_key_size": "0",
+		}},
+	} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			if tt.compact {
+				opts = append(opts, WithCompactNodeTitles())
+			}
+			Span(ctx, stats, opts...)
+		})
+		span := findSpan(t, spans, tt.wantName)
+		for key, want := range tt.wantAttrs {
+			if v, _ := attributeValue(span.Attributes(), key); v.AsString() != want {
+				t.Errorf("compact: %v, %s = %q, want %q", tt.compact, key, v.AsString(), want)
+			}
+		}
+		if _, ok := attributeValue(span.Attributes(), "metadata.scan_type"); ok {
+			t.Errorf("compact: %v, hidden field metadata.scan_type is set", tt.compact)
+		}
+	}
+}
+
+func TestSpanEmptyPlan(t *testing.T) {
+	stats := &spanner.ResultSetStats{QueryPlan: &spanner.QueryPlan{}}
+	spans, parent := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, opts...)
+	})
+	if len(spans) != 0 {
+		t.Errorf("spans = %q, want none", spanNames(spans))
+	}
+	if v, _ := attributeValue(parent.Attributes(), "spanner.plan_empty"); !v.AsBool() {
+		t.Errorf("spanner.plan_empty is not set")
+	}
+}
+
+func TestWithoutIndexPrefix(t *testing.T) {
+	// A chain of 11 nodes, whose indexes are padded to 2 digits.
+	var planNodes []*spanner.PlanNode
+	for i := int32(0); i <= 10; i++ {
+		node := &spanner.PlanNode{Index: i, Kind: spanner.PlanNode_RELATIONAL, DisplayName: "Limit"}
+		if i < 10 {
+			node.ChildLinks = []*spanner.PlanNode_ChildLink{{ChildIndex: i + 1}}
+		}
+		planNodes = append(planNodes, node)
+	}
+	stats := &spanner.ResultSetStats{QueryPlan: &spanner.QueryPlan{PlanNodes: planNodes}}
+
+	for _, tt := range []struct {
+		withoutIndexPrefix bool
+		wantRoot, wantLeaf string
+	}{
+		{false, "00: Limit", "10: Limit"},
+		{true, "Limit", "Limit"},
+	} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			if tt.withoutIndexPrefix {
+				opts = append(opts, WithoutIndexPrefix())
+			}
+			Span(ctx, stats, opts...)
+		})
+		leaf, root := spans[0], rootSpan(spans)
+		if leaf.Name() != tt.wantLeaf || root.Name() != tt.wantRoot {
+			t.Errorf("WithoutIndexPrefix: %v, names = (%q, %q), want (%q, %q)", tt.withoutIndexPrefix, root.Name(), leaf.Name(), tt.wantRoot, tt.wantLeaf)
+		}
+		if v, _ := attributeValue(leaf.Attributes(), "index"); v.AsInt64() != 10 {
+			t.Errorf("WithoutIndexPrefix: %v, index = %v, want 10", tt.withoutIndexPrefix, v.AsInt64())
+		}
+	}
+}
+
+func TestSpanWithoutExecutionStats(t *testing.T) {
+	// A plan of PLAN mode, which has no execution stats on any node.
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union", "childLinks": [{"childIndex": 1}, {"childIndex": 2, "type": "Split Range"}]},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}},
+	  {"index": 2, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "true"}}
+	]}}`)
+	now := time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, append(opts, WithClock(func() time.Time { return now }))...)
+	})
+
+	if len(spans) != 2 {
+		t.Fatalf("spans = %q, want 2 spans", spanNames(spans))
+	}
+	for _, span := range spans {
+		if !span.StartTime().Equal(now) || !span.EndTime().Equal(now) {
+			t.Errorf("%q is timestamped (%v, %v), want the clock %v", span.Name(), span.StartTime(), span.EndTime(), now)
+		}
+	}
+}
+
+func TestWithPlanAsSpanEvents(t *testing.T) {
+	spans, parent := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, mustStats(t, `{"queryPlan": `+testPlan+`}`), append(opts, WithPlanAsSpanEvents())...)
+	})
+	if len(spans) != 0 {
+		t.Errorf("spans = %q, want none", spanNames(spans))
+	}
+
+	want := []struct {
+		name               string
+		index, parentIndex int64
+		childLinkType      string
+	}{
+		{"Distributed Union", 0, -1, ""},
+		{"Distributed Cross Apply", 1, 0, ""},
+		{"Table Scan (Table: Albums)", 2, 1, "Input"},
+		{"Filter Scan", 3, 1, "Map"},
+		{"Table Scan (Table: Singers)", 4, 3, ""},
+	}
+	events := parent.Events()
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(events), len(want), events)
+	}
+	for i, want := range want {
+		event := events[i]
+		if event.Name != want.name {
+			t.Errorf("events[%d] name = %q, want %q", i, event.Name, want.name)
+		}
+		if v, _ := attributeValue(event.Attributes, "index"); v.AsInt64() != want.index {
+			t.Errorf("events[%d] index = %v, want %v", i, v.AsInt64(), want.index)
+		}
+		if v, _ := attributeValue(event.Attributes, "parent_index"); v.AsInt64() != want.parentIndex {
+			t.Errorf("events[%d] parent_index = %v, want %v", i, v.AsInt64(), want.parentIndex)
+		}
+		if v, _ := attributeValue(event.Attributes, "child_link_type"); v.AsString() != want.childLinkType {
+			t.Errorf("events[%d] child_link_type = %q, want %q", i, v.AsString(), want.childLinkType)
+		}
+	}
+}
+
+func TestWithConditionSubqueries(t *testing.T) {
+	// A plan of a query like SELECT * FROM Singers WHERE SingerId > (SELECT MAX(SingerId) FROM Albums).
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Filter", "childLinks": [{"childIndex": 1}, {"childIndex": 2, "type": "Condition"}]},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}},
+	  {"index": 2, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "($SingerId > $sq_1)", "subqueries": {"sq_1": 3}}},
+	  {"index": 3, "kind": "SCALAR", "displayName": "Scalar Subquery", "childLinks": [{"childIndex": 4}]},
+	  {"index": 4, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"}}
+	]}}`)
+	for _, enabled := range []bool{false, true} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			if enabled {
+				opts = append(opts, WithConditionSubqueries())
+			}
+			Span(ctx, stats, opts...)
+		})
+		attrs := findSpan(t, spans, "0: Filter").Attributes()
+		if v, _ := attributeValue(attrs, "Condition"); v.AsString() != "($SingerId > $sq_1)" {
+			t.Errorf("Condition = %q, want %q", v.AsString(), "($SingerId > $sq_1)")
+		}
+		v, ok := attributeValue(attrs, "Condition.subqueries")
+		if ok != enabled {
+			t.Errorf("WithConditionSubqueries: %v, Condition.subqueries is set: %v", enabled, ok)
+		}
+		if want := []string{"sq_1 = 3: Scalar Subquery"}; ok && !equalStrings(v.AsStringSlice(), want) {
+			t.Errorf("Condition.subqueries = %q, want %q", v.AsStringSlice(), want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWithReferencedTables(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Union All", "childLinks": [{"childIndex": 1}, {"childIndex": 2}, {"childIndex": 4}, {"childIndex": 6}]},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}},
+	  {"index": 2, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "IndexScan", "scan_target": "SingersByName"},
+	   "childLinks": [{"childIndex": 3, "type": "Seek Condition"}]},
+	  {"index": 3, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "($FirstName = 'a')"}},
+	  {"index": 4, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"},
+	   "childLinks": [{"childIndex": 5, "type": "Seek Condition"}]},
+	  {"index": 5, "kind": "SCALAR", "displayName": "Function", "shortRepresentation": {"description": "($SingerId = 1)"}},
+	  {"index": 6, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "IndexScan", "scan_target": "AlbumsByTitle"}}
+	]}}`)
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, append(opts, WithReferencedTables())...)
+	})
+
+	attrs := rootSpan(spans).Attributes()
+	for key, want := range map[string]int64{"spanner.index_seeks": 2, "spanner.table_scans": 1} {
+		if v, _ := attributeValue(attrs, key); v.AsInt64() != want {
+			t.Errorf("%s = %v, want %v", key, v.AsInt64(), want)
+		}
+	}
+	for key, want := range map[string][]string{
+		"spanner.tables":  {"Albums", "Singers"},
+		"spanner.indexes": {"AlbumsByTitle", "SingersByName"},
+	} {
+		if v, _ := attributeValue(attrs, key); !equalStrings(v.AsStringSlice(), want) {
+			t.Errorf("%s = %q, want %q", key, v.AsStringSlice(), want)
+		}
+	}
+}
+
+func TestChildLinkVariable(t *testing.T) {
+	// A plan of a Cross Apply, whose input binds its rows to the variable "s".
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Cross Apply",
+	   "childLinks": [{"childIndex": 1, "type": "Input", "variable": "s"}, {"childIndex": 2, "type": "Map"}]},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"}},
+	  {"index": 2, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Albums"}}
+	]}}`)
+	spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, opts...)
+	})
+
+	if v, _ := attributeValue(findSpan(t, spans, "1: [Input] Table Scan (Table: Singers)").Attributes(), "child_link_variable"); v.AsString() != "s" {
+		t.Errorf("child_link_variable = %q, want %q", v.AsString(), "s")
+	}
+	if v, ok := attributeValue(findSpan(t, spans, "2: [Map] Table Scan (Table: Albums)").Attributes(), "child_link_variable"); ok {
+		t.Errorf("child_link_variable = %q is set on a link without a variable", v.AsString())
+	}
+}
+
+func TestMetadataFieldVisibility(t *testing.T) {
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Scan",
+	   "metadata": {"call_type": "Local", "scan_type": "IndexScan", "scan_target": "SingersByName", "Full scan": "true"}}
+	]}}`)
+	for _, tt := range []struct {
+		desc     string
+		opts     []Option
+		wantName string
+	}{
+		{"default", nil, "0: Local Index Scan (Full scan: true, Index: SingersByName)"},
+		{"hidden field", []Option{WithHiddenMetadataFields("Full scan")}, "0: Local Index Scan (Index: SingersByName)"},
+		{"hidden scan_target", []Option{WithHiddenMetadataFields("scan_target")}, "0: Local Index Scan (Full scan: true)"},
+		{"visible field hidden by default", []Option{WithVisibleMetadataFields("call_type")},
+			"0: Local Index Scan (Full scan: true, Index: SingersByName, call_type: Local)"},
+		// scan_target is still rendered with the scan type when scan_type itself is visible.
+		{"visible scan_type", []Option{WithVisibleMetadataFields("scan_type")},
+			"0: Local Index Scan (Full scan: true, Index: SingersByName, scan_type: IndexScan)"},
+	} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			Span(ctx, stats, append(opts, tt.opts...)...)
+		})
+		if got := spanNames(spans); len(got) != 1 || got[0] != tt.wantName {
+			t.Errorf("%s: spans = %q, want %q", tt.desc, got, tt.wantName)
+		}
+	}
+}
+
+func TestSpanWithParent(t *testing.T) {
+	stats := &spanner.ResultSetStats{QueryPlan: &spanner.QueryPlan{PlanNodes: mustPlanNodes(t, testPlan)}}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, inContext := tp.Tracer("test").Start(context.Background(), "in context")
+	_, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+	SpanWithParent(ctx, parent, stats, WithTracerProvider(tp))
+	parent.End()
+	inContext.End()
+
+	ended := recorder.Ended()
+	root := rootSpan(ended[:len(ended)-2])
+	if root.Name() != "0: Distributed Union" {
+		t.Fatalf("root plan span = %q, want %q", root.Name(), "0: Distributed Union")
+	}
+	if root.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("root plan span is not a child of the given parent")
+	}
+	if root.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("root plan span is not in the trace of the given parent")
+	}
+}
+
+func TestWithPlanParentSpan(t *testing.T) {
+	stats := &spanner.ResultSetStats{QueryPlan: &spanner.QueryPlan{PlanNodes: mustPlanNodes(t, testPlan)}}
+	spans, parent := recordSpans(t, func(ctx context.Context, opts ...Option) {
+		Span(ctx, stats, append(opts, WithPlanParentSpan())...)
+	})
+
+	planSpan := findSpan(t, spans, "query plan")
+	if planSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("query plan span is not a child of the parent span")
+	}
+	// The execution_summary of the root node is from 1600000000.000000 to 1600000000.002000.
+	if want := time.Unix(1600000000, 0); !planSpan.StartTime().Equal(want) {
+		t.Errorf("query plan start = %v, want %v", planSpan.StartTime(), want)
+	}
+	if want := time.Unix(1600000000, 2*int64(time.Millisecond)); !planSpan.EndTime().Equal(want) {
+		t.Errorf("query plan end = %v, want %v", planSpan.EndTime(), want)
+	}
+	for _, span := range spans {
+		if span.Name() == "query plan" {
+			continue
+		}
+		if span.StartTime().Before(planSpan.StartTime()) || span.EndTime().After(planSpan.EndTime()) {
+			t.Errorf("%q (%v - %v) is not covered by the query plan span", span.Name(), span.StartTime(), span.EndTime())
+		}
+	}
+	if root := findSpan(t, spans, "0: Distributed Union"); root.Parent().SpanID() != planSpan.SpanContext().SpanID() {
+		t.Errorf("root plan span is not a child of the query plan span")
+	}
+}
+
+func TestWithSkipZeroDurationNodes(t *testing.T) {
+	// Compute takes no time, but the scan under it does.
+	stats := mustStats(t, `{"queryPlan": {"planNodes": [
+	  {"index": 0, "kind": "RELATIONAL", "displayName": "Serialize Result", "childLinks": [{"childIndex": 1}],
+	   "executionStats": {"execution_summary": {"execution_start_timestamp": "1600000000.000000", "execution_end_timestamp": "1600000000.002000"}}},
+	  {"index": 1, "kind": "RELATIONAL", "displayName": "Compute", "childLinks": [{"childIndex": 2}],
+	   "executionStats": {"execution_summary": {"execution_start_timestamp": "1600000000.001000", "execution_end_timestamp": "1600000000.001000"}}},
+	  {"index": 2, "kind": "RELATIONAL", "displayName": "Scan", "metadata": {"scan_type": "TableScan", "scan_target": "Singers"},
+	   "executionStats": {"execution_summary": {"execution_start_timestamp": "1600000000.000500", "execution_end_timestamp": "1600000000.001500"}}}
+	]}}`)
+	for _, tt := range []struct {
+		skip      bool
+		wantNames []string
+		// wantScanParent is the name of the parent span of the scan.
+		wantScanParent string
+	}{
+		{false, []string{"2: Table Scan (Table: Singers)", "1: Compute", "0: Serialize Result"}, "1: Compute"},
+		{true, []string{"2: Table Scan (Table: Singers)", "0: Serialize Result"}, "0: Serialize Result"},
+	} {
+		spans, _ := recordSpans(t, func(ctx context.Context, opts ...Option) {
+			if tt.skip {
+				opts = append(opts, WithSkipZeroDurationNodes())
+			}
+			Span(ctx, stats, opts...)
+		})
+		if got := spanNames(spans); !equalStrings(got, tt.wantNames) {
+			t.Fatalf("skip: %v, spans = %q, want %q", tt.skip, got, tt.wantNames)
+		}
+		scan, parent := findSpan(t, spans, tt.wantNames[0]), findSpan(t, spans, tt.wantScanParent)
+		if scan.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("skip: %v, parent of the scan is not %q", tt.skip, tt.wantScanParent)
+		}
+		if got, want := scan.EndTime().Sub(scan.StartTime()), time.Millisecond; got != want {
+			t.Errorf("skip: %v, scan duration = %v, want %v", tt.skip, got, want)
+		}
+	}
+}
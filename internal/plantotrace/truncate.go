@@ -0,0 +1,66 @@
+package plantotrace
+
+import (
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ellipsis = "…"
+
+// TruncateString truncates s to at most n bytes with an ellipsis without breaking UTF-8 sequences.
+// n <= 0 means no limit.
+func TruncateString(s string, n int) (string, bool) {
+	if n <= 0 || len(s) <= n {
+		return s, false
+	}
+	limit := n - len(ellipsis)
+	if limit < 0 {
+		limit = 0
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	if n < len(ellipsis) {
+		return s[:limit], true
+	}
+	return s[:limit] + ellipsis, true
+}
+
+// TruncateAttributes truncates string attributes longer than n bytes
+// and adds "<key>.truncated" boolean attributes for them. n <= 0 means no limit.
+func TruncateAttributes(n int, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	if n <= 0 {
+		return attrs
+	}
+	result := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if kv.Value.Type() == attribute.STRING {
+			if s, truncated := TruncateString(kv.Value.AsString(), n); truncated {
+				result = append(result, kv.Key.String(s), attribute.Bool(string(kv.Key)+".truncated", true))
+				continue
+			}
+		}
+		result = append(result, kv)
+	}
+	return result
+}
+
+type truncatingSpan struct {
+	trace.Span
+	n int
+}
+
+func (s *truncatingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.Span.SetAttributes(TruncateAttributes(s.n, kv...)...)
+}
+
+// TruncatingSpan wraps span so that string attributes longer than n bytes are truncated by TruncateAttributes.
+// n <= 0 means no limit.
+func TruncatingSpan(span trace.Span, n int) trace.Span {
+	if n <= 0 {
+		return span
+	}
+	return &truncatingSpan{Span: span, n: n}
+}
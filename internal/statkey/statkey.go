@@ -0,0 +1,41 @@
+// Package statkey defines attribute keys of numeric stats with their units in one place
+// so that they stay consistent across decorators.
+package statkey
+
+import "go.opentelemetry.io/otel/attribute"
+
+// Style is the style of joining stat names and units in attribute keys.
+type Style int
+
+const (
+	// Underscore joins names and units with "_", e.g. elapsed_time_ms. It is the default.
+	Underscore Style = iota
+	// Dot joins names and units with ".", e.g. elapsed_time.ms.
+	Dot
+)
+
+// Key is a stat name with its unit.
+type Key struct {
+	Name string
+	Unit string
+}
+
+// Format returns the attribute key of k in style.
+func (k Key) Format(style Style) attribute.Key {
+	if style == Dot {
+		return attribute.Key(k.Name + "." + k.Unit)
+	}
+	return attribute.Key(k.Name + "_" + k.Unit)
+}
+
+var (
+	ElapsedTime     = Key{Name: "elapsed_time", Unit: "ms"}
+	CPUTime         = Key{Name: "cpu_time", Unit: "ms"}
+	Latency         = Key{Name: "latency", Unit: "ms"}
+	WaitTime        = Key{Name: "wait_time", Unit: "ms"}
+	ClientOverhead  = Key{Name: "spanner.client_overhead", Unit: "ms"}
+	MemoryPeakUsage = Key{Name: "memory_peak_usage", Unit: "bytes"}
+	FilesystemDelay = Key{Name: "filesystem_delay", Unit: "s"}
+	LockingDelay    = Key{Name: "spanner.locking_delay", Unit: "ms"}
+	TimeToFirstRow  = Key{Name: "spanner.time_to_first_row", Unit: "ms"}
+)
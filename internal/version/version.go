@@ -0,0 +1,4 @@
+package version
+
+// Version is the version of spannerotel. It is recorded as the instrumentation version of tracers.
+const Version = "0.1.0"
@@ -0,0 +1,23 @@
+// Package spannerotel provides a convenience constructor of Cloud Spanner clients instrumented by
+// github.com/apstndb/spannerotel/interceptor.
+package spannerotel
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apstndb/spannerotel/interceptor"
+	"github.com/apstndb/spannerotel/internal/version"
+	"google.golang.org/api/option"
+)
+
+// Version is the version of spannerotel.
+const Version = version.Version
+
+// NewClient is like spanner.NewClientWithConfig, but installs the stream and unary interceptors
+// with the default decorators followed by interceptorOpts.
+// clientOpts, e.g. credentials and endpoints, are passed to spanner.NewClientWithConfig after the interceptors.
+func NewClient(ctx context.Context, database string, config spanner.ClientConfig, interceptorOpts []interceptor.Option, clientOpts ...option.ClientOption) (*spanner.Client, error) {
+	interceptorOpts = append([]interceptor.Option{interceptor.WithDefaultDecorators()}, interceptorOpts...)
+	return spanner.NewClientWithConfig(ctx, database, config, append(interceptor.ClientOptions(interceptorOpts...), clientOpts...)...)
+}
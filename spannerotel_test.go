@@ -0,0 +1,96 @@
+package spannerotel
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apstndb/spannerotel/interceptor"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeSpannerServer creates sessions and returns an empty result set with query stats for any query.
+type fakeSpannerServer struct {
+	sppb.UnimplementedSpannerServer
+}
+
+func (s *fakeSpannerServer) CreateSession(ctx context.Context, req *sppb.CreateSessionRequest) (*sppb.Session, error) {
+	return &sppb.Session{Name: req.GetDatabase() + "/sessions/s"}, nil
+}
+
+func (s *fakeSpannerServer) BatchCreateSessions(ctx context.Context, req *sppb.BatchCreateSessionsRequest) (*sppb.BatchCreateSessionsResponse, error) {
+	return &sppb.BatchCreateSessionsResponse{Session: []*sppb.Session{{Name: req.GetDatabase() + "/sessions/s"}}}, nil
+}
+
+func (s *fakeSpannerServer) DeleteSession(ctx context.Context, req *sppb.DeleteSessionRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *fakeSpannerServer) ExecuteStreamingSql(req *sppb.ExecuteSqlRequest, stream sppb.Spanner_ExecuteStreamingSqlServer) error {
+	queryStats, err := structpb.NewStruct(map[string]interface{}{"query_text": req.GetSql(), "rows_returned": "0"})
+	if err != nil {
+		return err
+	}
+	return stream.Send(&sppb.PartialResultSet{
+		Metadata: &sppb.ResultSetMetadata{RowType: &sppb.StructType{}},
+		Stats:    &sppb.ResultSetStats{QueryStats: queryStats},
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	sppb.RegisterSpannerServer(server, &fakeSpannerServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx := context.Background()
+	// The client can reach the fake server only if the client options are passed.
+	client, err := NewClient(ctx, "projects/p/instances/i/databases/d", spanner.ClientConfig{}, []interceptor.Option{interceptor.WithTracerProvider(tp), interceptor.WithCreateSpan()},
+		option.WithEndpoint("bufnet"),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+		option.WithGRPCDialOption(grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.Dial()
+		})),
+	)
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	it := client.Single().QueryWithStats(ctx, spanner.NewStatement("SELECT 1"))
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("query returned error: %v", err)
+		}
+	}
+	client.Close()
+
+	// The span of the query is decorated by the default decorators.
+	for _, span := range recorder.Ended() {
+		if span.Name() != "google.spanner.v1.Spanner/ExecuteStreamingSql" {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "query_text" && attr.Value.AsString() == "SELECT 1" {
+				return
+			}
+		}
+		t.Fatalf("query_text is not set on the span: %v", span.Attributes())
+	}
+	t.Errorf("no span of ExecuteStreamingSql in %d spans", len(recorder.Ended()))
+}